@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"rbdb-backend-go/config"
+	"rbdb-backend-go/internal/queue"
+)
+
+const deadLetterUsage = `Usage: main dlq <command> [arguments]
+
+Commands:
+  list [-limit N] [-offset N]   List dead-lettered jobs, newest first
+  show <id>                     Print a dead-lettered job with its attempt history
+  replay <id>... | -all         Push jobs back onto their priority lane
+  purge <id>... | -all          Delete jobs from the dead-letter queue
+`
+
+// runDeadLetterCommand implements the "dlq" operator commands and returns
+// the process exit code.
+func runDeadLetterCommand(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, deadLetterUsage)
+		return 2
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid configuration:\n%v\n", err)
+		return 1
+	}
+	rdb, err := newRedisClient(cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "redis: %v\n", err)
+		return 1
+	}
+	defer rdb.Close()
+	q := newQueue(rdb, cfg)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	switch args[0] {
+	case "list":
+		err = listDeadLetters(ctx, q, args[1:])
+	case "show":
+		err = showDeadLetter(ctx, q, args[1:])
+	case "replay":
+		err = forEachDeadLetter(ctx, q, "replay", args[1:], q.ReplayDeadLetter)
+	case "purge":
+		err = forEachDeadLetter(ctx, q, "purge", args[1:], q.PurgeDeadLetter)
+	default:
+		fmt.Fprint(os.Stderr, deadLetterUsage)
+		return 2
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "dlq %s: %v\n", args[0], err)
+		return 1
+	}
+	return 0
+}
+
+func listDeadLetters(ctx context.Context, q *queue.Queue, args []string) error {
+	fs := flag.NewFlagSet("list", flag.ContinueOnError)
+	limit := fs.Int64("limit", 50, "maximum number of entries")
+	offset := fs.Int64("offset", 0, "number of entries to skip")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	entries, err := q.DeadLetters(ctx, *offset, *limit)
+	if err != nil {
+		return err
+	}
+	total, err := q.CountDeadLetters(ctx)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tDEAD AT\tREASON\tEXECUTION\tATTEMPTS\tERROR")
+	for _, e := range entries {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\t%s\n",
+			e.ID, e.DeadAt.Format(time.RFC3339), e.Reason, e.ExecutionID, len(e.Attempts), truncate(e.Error, 60))
+	}
+	w.Flush()
+	fmt.Printf("%d of %d entries\n", len(entries), total)
+	return nil
+}
+
+func showDeadLetter(ctx context.Context, q *queue.Queue, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("expected exactly one id")
+	}
+
+	entry, err := q.GetDeadLetter(ctx, args[0])
+	if err != nil {
+		return err
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(entry)
+}
+
+// forEachDeadLetter applies action to the given IDs, or to every stored
+// entry when -all is passed.
+func forEachDeadLetter(ctx context.Context, q *queue.Queue, verb string, args []string, action func(context.Context, string) error) error {
+	fs := flag.NewFlagSet(verb, flag.ContinueOnError)
+	all := fs.Bool("all", false, "apply to every entry")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	ids := fs.Args()
+	if *all {
+		if len(ids) > 0 {
+			return fmt.Errorf("pass either ids or -all, not both")
+		}
+		entries, err := q.DeadLetters(ctx, 0, 0)
+		if err != nil {
+			return err
+		}
+		for _, e := range entries {
+			ids = append(ids, e.ID)
+		}
+	}
+	if len(ids) == 0 {
+		return fmt.Errorf("no ids given")
+	}
+
+	failed := 0
+	for _, id := range ids {
+		if err := action(ctx, id); err != nil {
+			fmt.Fprintf(os.Stderr, "%s %s: %v\n", verb, id, err)
+			failed++
+			continue
+		}
+		fmt.Printf("%s %s: ok\n", verb, id)
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d entries failed", failed, len(ids))
+	}
+	return nil
+}
+
+func truncate(s string, n int) string {
+	s = strings.ReplaceAll(s, "\n", " ")
+	if len(s) <= n {
+		return s
+	}
+	return s[:n-3] + "..."
+}
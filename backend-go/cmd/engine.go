@@ -0,0 +1,39 @@
+package main
+
+import (
+	"os"
+	"time"
+
+	"rbdb-backend-go/config"
+	"rbdb-backend-go/internal/delivery"
+	"rbdb-backend-go/internal/models"
+	"rbdb-backend-go/internal/output"
+	"rbdb-backend-go/internal/report_builder"
+)
+
+// engineRegistration describes this engine to the control plane.
+func engineRegistration(cfg *config.Config) models.EngineRegistration {
+	hostname, _ := os.Hostname()
+
+	formats := make([]string, len(output.Formats))
+	for i, f := range output.Formats {
+		formats[i] = string(f)
+	}
+	deliveries := make([]string, len(delivery.StreamTypes))
+	for i, t := range delivery.StreamTypes {
+		deliveries[i] = string(t)
+	}
+
+	return models.EngineRegistration{
+		EngineID:          cfg.EngineID,
+		Version:           version,
+		Hostname:          hostname,
+		WorkerCount:       cfg.WorkerCount,
+		QueueMode:         cfg.QueueMode,
+		DBTypes:           report_builder.DatabaseTypes,
+		OutputFormats:     formats,
+		DeliveryTypes:     deliveries,
+		HeartbeatInterval: int(cfg.EngineHeartbeatInterval.Seconds()),
+		StartedAt:         time.Now(),
+	}
+}
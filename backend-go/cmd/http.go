@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"rbdb-backend-go/internal/api_client"
+	"rbdb-backend-go/internal/executor"
+	"rbdb-backend-go/internal/health"
+	"rbdb-backend-go/internal/metrics"
+	"rbdb-backend-go/internal/queue"
+)
+
+// startHTTPServer serves the operational endpoints on addr. An empty addr
+// disables the server. Readiness fails once stopping is set, so
+// orchestrators stop routing to the engine while it drains. q is nil in poll
+// mode, where Redis is not used.
+func startHTTPServer(addr string, q *queue.Queue, client *api_client.Client, pool *executor.Pool, stopping *atomic.Bool) *http.Server {
+	if addr == "" {
+		return nil
+	}
+
+	var checks []health.Check
+	if q != nil {
+		checks = append(checks, health.Check{Name: "redis", Run: q.Ping})
+	}
+	checks = append(checks,
+		health.Check{Name: "control_plane", Run: client.Ping},
+		health.Check{Name: "workers", Run: func(context.Context) error {
+			if stopping.Load() {
+				return errors.New("draining for shutdown")
+			}
+			if running := pool.Running(); running >= pool.Workers() {
+				return fmt.Errorf("all %d workers busy", running)
+			}
+			return nil
+		}},
+	)
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metrics.Handler())
+	mux.Handle("/healthz", health.Liveness())
+	mux.Handle("/readyz", health.Readiness(checks...))
+
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+	go func() {
+		slog.Info("HTTP server listening", "addr", addr)
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			slog.Error("HTTP server failed", "error", err)
+		}
+	}()
+	return srv
+}
+
+func stopHTTPServer(srv *http.Server) {
+	if srv == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		slog.Error("HTTP server shutdown failed", "error", err)
+	}
+}
@@ -2,68 +2,249 @@ package main
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"os"
 	"os/signal"
 	"rbdb-backend-go/config"
 	"rbdb-backend-go/internal/api_client"
 	"rbdb-backend-go/internal/executor"
+	"rbdb-backend-go/internal/fleet"
+	"rbdb-backend-go/internal/jobsource"
+	"rbdb-backend-go/internal/logging"
+	"rbdb-backend-go/internal/metrics"
 	"rbdb-backend-go/internal/models"
+	"rbdb-backend-go/internal/outbox"
+	"rbdb-backend-go/internal/queue"
+	"rbdb-backend-go/internal/tracing"
+	"sync/atomic"
 	"syscall"
 	"time"
-
-	"github.com/redis/go-redis/v9"
 )
 
+// version is set at build time with -ldflags "-X main.version=...".
+var version = "dev"
+
 func main() {
-	log.Println("Starting RBDB Execution Engine (Redis-Driven)...")
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "dlq":
+			os.Exit(runDeadLetterCommand(os.Args[2:]))
+		default:
+			fmt.Fprintf(os.Stderr, "unknown command %q\n", os.Args[1])
+			os.Exit(2)
+		}
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid configuration:\n%v\n", err)
+		os.Exit(1)
+	}
+	slog.SetDefault(logging.Setup(cfg.LogLevel, cfg.LogFormat).With("engine_id", cfg.EngineID))
+	logging.AddSecret(cfg.ControlPlaneToken)
+	logging.AddSecret(cfg.RedisPassword)
+	logging.AddSecret(cfg.RedisSentinelPassword)
+
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.TracingExporter, cfg.TracingFile, cfg.EngineID)
+	if err != nil {
+		slog.Error("Tracing disabled", "exporter", cfg.TracingExporter, "error", err)
+		shutdownTracing = func(context.Context) error { return nil }
+	}
 
-	cfg := config.Load()
-	log.Printf("Environment: %s, Workers: %d, Redis: %s:%s", 
-		cfg.Environment, cfg.WorkerCount, cfg.RedisHost, cfg.RedisPort)
+	slog.Info("Starting RBDB Execution Engine",
+		"version", version, "environment", cfg.Environment, "workers", cfg.WorkerCount, "queue_mode", cfg.QueueMode)
+	ctx := context.Background()
 
 	client := api_client.NewClient(cfg)
-	pool := executor.NewPool(cfg, client)
+	box, err := outbox.Open(cfg.OutboxFile, client)
+	if err != nil {
+		slog.Error("Opening the status outbox failed", "error", err)
+		os.Exit(1)
+	}
+	outboxCtx, stopOutbox := context.WithCancel(ctx)
+	outboxDone := make(chan struct{})
+	go func() {
+		defer close(outboxDone)
+		box.Run(outboxCtx)
+	}()
+
+	source, q := startSource(ctx, cfg, client)
+	go source.Maintain(ctx)
+	pool := executor.NewPool(cfg, client, source)
+	pool.Outbox = box
 	pool.Start()
 
-	// Redis client
-	rdb := redis.NewClient(&redis.Options{
-		Addr: fmt.Sprintf("%s:%s", cfg.RedisHost, cfg.RedisPort),
-	})
+	var stopping atomic.Bool
+	httpServer := startHTTPServer(cfg.HTTPAddr, q, client, pool, &stopping)
 
-	ctx := context.Background()
+	reporter := &fleet.Reporter{
+		Client:   client,
+		Info:     engineRegistration(cfg),
+		Interval: cfg.EngineHeartbeatInterval,
+		State: func() models.EngineHeartbeat {
+			status := models.EngineRunning
+			if stopping.Load() {
+				status = models.EngineDraining
+			}
+			return models.EngineHeartbeat{
+				Status:           status,
+				WorkerCount:      pool.Workers(),
+				RunningCount:     pool.Running(),
+				ActiveExecutions: pool.ActiveExecutions(),
+			}
+		},
+	}
+	reporterCtx, stopReporter := context.WithCancel(ctx)
+	reporterDone := make(chan struct{})
+	go func() {
+		defer close(reporterDone)
+		reporter.Run(reporterCtx)
+	}()
+
+	// Operator commands such as cancellations arrive on the control channel.
+	// In poll mode they come with the claim renewals instead.
+	if q != nil {
+		go q.Subscribe(ctx, func(msg queue.ControlMessage) {
+			switch msg.Action {
+			case queue.ActionCancel:
+				pool.CancelExecution(ctx, msg.ExecutionID)
+			default:
+				slog.Warn("Unknown control action", "action", msg.Action, logging.KeyExecutionID, msg.ExecutionID)
+			}
+		})
+	}
 
 	// Handle graceful shutdown
 	stop := make(chan os.Signal, 1)
 	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
 
-	// Redis Consumer Loop
+	consumeCtx, stopConsuming := context.WithCancel(ctx)
+	consumerDone := make(chan struct{})
 	go func() {
-		log.Println("Listening for manual pulse executions in Redis...")
-		for {
-			// BLPop blocks until a job is available
-			result, err := rdb.BLPop(ctx, 0, "rbdb_execution_queue").Result()
-			if err != nil {
-				log.Printf("Redis BLPop error: %v", err)
-				time.Sleep(2 * time.Second)
-				continue
-			}
+		defer close(consumerDone)
+		consume(consumeCtx, source, pool, client)
+	}()
 
-			// index 0 is the key name, index 1 is the value
-			payload := result[1]
-			var job models.Job
-			if err := json.Unmarshal([]byte(payload), &job); err != nil {
-				log.Printf("Payload Parse Error: %v (Payload: %s)", err, payload)
+	for waiting := true; waiting; {
+		select {
+		case <-hup:
+			cfg = reloadConfig(cfg, pool)
+		case <-stop:
+			waiting = false
+		}
+	}
+	stopping.Store(true)
+	slog.Info("Engine shutting down, draining", "grace", cfg.ShutdownGrace.String())
+	stopConsuming()
+	<-consumerDone
+	pool.Shutdown(cfg.ShutdownGrace)
+
+	stopReporter()
+	<-reporterDone
+	stopCtx, cancelStop := context.WithTimeout(context.Background(), 5*time.Second)
+	if err := reporter.Stop(stopCtx); err != nil {
+		slog.Error("Final engine heartbeat failed", "error", err)
+	}
+	cancelStop()
+
+	// Stop background delivery so the final flush is the only sender.
+	stopOutbox()
+	<-outboxDone
+	flushOutbox(box)
+
+	if q != nil {
+		if err := q.Deregister(ctx); err != nil {
+			slog.Error("Queue deregister failed", "error", err)
+		}
+	}
+	stopHTTPServer(httpServer)
+
+	flushCtx, cancelFlush := context.WithTimeout(context.Background(), 5*time.Second)
+	if err := shutdownTracing(flushCtx); err != nil {
+		slog.Error("Flushing traces failed", "error", err)
+	}
+	cancelFlush()
+	slog.Info("Engine stopped")
+}
+
+// flushOutbox makes a last attempt at the pending status updates. Those
+// left are sent after the next start.
+func flushOutbox(box *outbox.Outbox) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if left := box.Flush(ctx); left > 0 {
+		slog.Warn("Status updates left in the outbox for the next start", "count", left)
+	}
+	if err := box.Close(); err != nil {
+		slog.Error("Closing the status outbox failed", "error", err)
+	}
+}
+
+// startSource sets up the job source selected by queue_mode. The Redis
+// queue is returned as well unless the engine polls the control plane.
+func startSource(ctx context.Context, cfg *config.Config, client *api_client.Client) (jobsource.JobSource, *queue.Queue) {
+	if cfg.QueueMode == config.QueueModePoll {
+		slog.Info("Claiming executions from the control plane", "url", cfg.ControlPlaneURL,
+			"interval", cfg.PollInterval.String(), "lease", cfg.PollLease.String())
+		return jobsource.NewPoll(client, cfg.EngineID, cfg.PollInterval, cfg.PollLease), nil
+	}
+
+	slog.Info("Connecting to Redis", "redis", redisTarget(cfg), "queue", cfg.QueueName)
+	rdb, err := newRedisClient(cfg)
+	if err != nil {
+		slog.Error("Redis client could not be configured", "error", err)
+		os.Exit(1)
+	}
+	q := newQueue(rdb, cfg)
+	if err := q.Heartbeat(ctx); err != nil {
+		slog.Error("Queue heartbeat failed", "error", err)
+	}
+	if n, err := q.Recover(ctx); err != nil {
+		slog.Error("Startup sweep failed", "error", err)
+	} else if n > 0 {
+		slog.Info("Startup sweep requeued unfinished jobs", "count", n)
+	}
+	metrics.RegisterQueueDepth(q.Depth)
+	return jobsource.NewRedis(q), q
+}
+
+// consume is the consumer loop. It returns once ctx is cancelled.
+func consume(ctx context.Context, source jobsource.JobSource, pool *executor.Pool, client *api_client.Client) {
+	slog.Info("Listening for executions")
+	for ctx.Err() == nil {
+		// Jobs taken while the control plane is down could neither load
+		// their report nor report back.
+		if !client.Available() {
+			slog.Warn("Control plane unavailable, pausing consumption")
+			if client.WaitAvailable(ctx) != nil {
 				continue
 			}
+			slog.Info("Resuming consumption")
+		}
 
-			log.Printf("Pulse received: Execution %s for Report %s", job.ExecutionID, job.ReportID)
-			pool.AddJob(job)
+		// Next blocks until a job is available; the source keeps it
+		// assigned to this engine until it is acked.
+		job, err := source.Next(ctx, 5*time.Second)
+		if errors.Is(err, jobsource.ErrEmpty) || ctx.Err() != nil {
+			continue
+		}
+		if err != nil {
+			slog.Error("Fetching the next job failed", "error", err)
+			time.Sleep(2 * time.Second)
+			continue
 		}
-	}()
 
-	<-stop
-	log.Println("Engine shutting down...")
+		slog.Info("Pulse received", logging.KeyExecutionID, job.ExecutionID,
+			logging.KeyReportID, job.ReportID, logging.KeyJobID, job.JobID, "lane", job.Receipt.Lane, "receipt_id", job.Receipt.ID)
+		if err := pool.AddJob(ctx, job); err != nil {
+			// Shutting down before a worker took it: hand it straight back.
+			if _, err := source.Requeue(context.Background(), job); err != nil {
+				slog.Error("Job could not be returned to the queue", logging.KeyExecutionID, job.ExecutionID, "error", err)
+			}
+		}
+	}
 }
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"rbdb-backend-go/config"
+	"rbdb-backend-go/internal/queue"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// newRedisClient connects to a Redis URL, a Sentinel-managed master, a
+// cluster or a single server, in that order of preference.
+func newRedisClient(cfg *config.Config) (redis.UniversalClient, error) {
+	tlsConfig, err := redisTLSConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case cfg.RedisURL != "":
+		opts, err := redis.ParseURL(cfg.RedisURL)
+		if err != nil {
+			return nil, errors.New("redis_url could not be parsed")
+		}
+		// rediss:// only turns TLS on; the CA and client certificate still
+		// come from the redis_tls_* settings.
+		if opts.TLSConfig != nil || tlsConfig != nil {
+			if tlsConfig == nil {
+				tlsConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+			}
+			if tlsConfig.ServerName == "" && opts.TLSConfig != nil {
+				tlsConfig.ServerName = opts.TLSConfig.ServerName
+			}
+			opts.TLSConfig = tlsConfig
+		}
+		return redis.NewClient(opts), nil
+
+	case cfg.RedisSentinelMaster != "":
+		return redis.NewFailoverClient(&redis.FailoverOptions{
+			MasterName:       cfg.RedisSentinelMaster,
+			SentinelAddrs:    cfg.RedisSentinelAddrs,
+			SentinelUsername: cfg.RedisSentinelUsername,
+			SentinelPassword: cfg.RedisSentinelPassword,
+			Username:         cfg.RedisUsername,
+			Password:         cfg.RedisPassword,
+			DB:               cfg.RedisDB,
+			TLSConfig:        tlsConfig,
+		}), nil
+
+	case len(cfg.RedisClusterAddrs) > 0:
+		return redis.NewClusterClient(&redis.ClusterOptions{
+			Addrs:     cfg.RedisClusterAddrs,
+			Username:  cfg.RedisUsername,
+			Password:  cfg.RedisPassword,
+			TLSConfig: tlsConfig,
+		}), nil
+
+	default:
+		if tlsConfig != nil && tlsConfig.ServerName == "" {
+			tlsConfig.ServerName = cfg.RedisHost
+		}
+		return redis.NewClient(&redis.Options{
+			Addr:      fmt.Sprintf("%s:%s", cfg.RedisHost, cfg.RedisPort),
+			Username:  cfg.RedisUsername,
+			Password:  cfg.RedisPassword,
+			DB:        cfg.RedisDB,
+			TLSConfig: tlsConfig,
+		}), nil
+	}
+}
+
+// redisTLSConfig returns nil unless TLS is enabled. The CA file replaces the
+// system roots; a certificate and key enable client authentication.
+func redisTLSConfig(cfg *config.Config) (*tls.Config, error) {
+	if !cfg.RedisTLS && !strings.HasPrefix(cfg.RedisURL, "rediss://") {
+		return nil, nil
+	}
+
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		ServerName:         cfg.RedisTLSServerName,
+		InsecureSkipVerify: cfg.RedisTLSSkipVerify,
+	}
+	if cfg.RedisTLSCAFile != "" {
+		pem, err := os.ReadFile(cfg.RedisTLSCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("redis_tls_ca_file: %w", err)
+		}
+		roots := x509.NewCertPool()
+		if !roots.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("redis_tls_ca_file: no PEM certificates in %s", cfg.RedisTLSCAFile)
+		}
+		tlsConfig.RootCAs = roots
+	}
+	if cfg.RedisTLSCertFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.RedisTLSCertFile, cfg.RedisTLSKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("redis_tls_cert_file: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return tlsConfig, nil
+}
+
+// redisTarget describes where the engine connects to, for the startup log.
+// The logger redacts credentials in URLs.
+func redisTarget(cfg *config.Config) string {
+	switch {
+	case cfg.RedisURL != "":
+		return cfg.RedisURL
+	case cfg.RedisSentinelMaster != "":
+		return fmt.Sprintf("sentinel %s via %s", cfg.RedisSentinelMaster, strings.Join(cfg.RedisSentinelAddrs, ","))
+	case len(cfg.RedisClusterAddrs) > 0:
+		return "cluster " + strings.Join(cfg.RedisClusterAddrs, ",")
+	default:
+		return fmt.Sprintf("%s:%s", cfg.RedisHost, cfg.RedisPort)
+	}
+}
+
+// newQueue returns the list or stream queue selected by queue_mode.
+func newQueue(rdb redis.UniversalClient, cfg *config.Config) *queue.Queue {
+	if cfg.QueueMode == config.QueueModeStream {
+		return queue.NewStream(rdb, cfg.QueueName, cfg.EngineID, queue.StreamOptions{
+			Group:     cfg.QueueStreamGroup,
+			ClaimIdle: cfg.QueueStreamClaimIdle,
+			Retention: cfg.QueueStreamRetention,
+		})
+	}
+	return queue.New(rdb, cfg.QueueName, cfg.EngineID)
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"log/slog"
+
+	"rbdb-backend-go/config"
+	"rbdb-backend-go/internal/executor"
+	"rbdb-backend-go/internal/logging"
+)
+
+// reloadConfig re-reads the configuration on SIGHUP and applies what can
+// change at runtime. An invalid configuration is rejected as a whole. It
+// returns the configuration now in effect.
+func reloadConfig(current *config.Config, pool *executor.Pool) *config.Config {
+	next, err := config.Load()
+	if err != nil {
+		slog.Error("Configuration reload rejected, keeping the current settings", "error", err)
+		return current
+	}
+
+	applied, restart := current.Reload(next)
+	if len(restart) > 0 {
+		slog.Warn("Changed settings take effect after a restart", "settings", restart)
+	}
+	logging.SetLevel(applied.LogLevel)
+	pool.Reload(applied)
+
+	slog.Info("Configuration reloaded",
+		"workers", applied.WorkerCount,
+		"data_source_limits", applied.DataSourceLimits,
+		"data_source_type_limits", applied.DataSourceTypeLimits,
+		"job_timeout", applied.JobTimeout.String(),
+		"log_level", applied.LogLevel)
+	return applied
+}
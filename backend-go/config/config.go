@@ -1,35 +1,476 @@
 package config
 
 import (
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
 	"os"
 	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Queue modes accepted in QueueMode.
+const (
+	QueueModeList   = "list"
+	QueueModeStream = "stream"
+	QueueModePoll   = "poll"
 )
 
+// Config holds the engine settings. Each field can be set in the YAML file
+// named by CONFIG_FILE, under the key in its yaml tag, and is overridden by
+// the matching environment variable.
 type Config struct {
-	ControlPlaneURL   string
-	ControlPlaneToken string
-	WorkerCount       int
-	Environment       string
-	RedisHost         string
-	RedisPort         string
+	ControlPlaneURL     string        `yaml:"control_plane_url"`
+	ControlPlaneToken   string        `yaml:"control_plane_token"`
+	ControlPlaneTimeout time.Duration `yaml:"control_plane_timeout"`
+	WorkerCount         int           `yaml:"worker_count"`
+	Environment         string        `yaml:"app_env"`
+	EngineID            string        `yaml:"engine_id"`
+	ShutdownGrace       time.Duration `yaml:"shutdown_grace"`
+	HTTPAddr            string        `yaml:"http_addr"`
+
+	// Failed control plane requests are retried ControlPlaneRetries times.
+	// After ControlPlaneBreakerThreshold failures in a row the engine stops
+	// calling it for ControlPlaneBreakerCooldown.
+	ControlPlaneRetries          int           `yaml:"control_plane_retries"`
+	ControlPlaneBreakerThreshold int           `yaml:"control_plane_breaker_threshold"`
+	ControlPlaneBreakerCooldown  time.Duration `yaml:"control_plane_breaker_cooldown"`
+
+	// EngineHeartbeatInterval is how often the engine tells the control
+	// plane it is alive and what it runs.
+	EngineHeartbeatInterval time.Duration `yaml:"engine_heartbeat_interval"`
+
+	// RedisURL (redis://, rediss:// or unix://) replaces the host, port,
+	// credentials and database settings below.
+	RedisURL      string `yaml:"redis_url"`
+	RedisHost     string `yaml:"redis_host"`
+	RedisPort     string `yaml:"redis_port"`
+	RedisUsername string `yaml:"redis_username"`
+	RedisPassword string `yaml:"redis_password"`
+	RedisDB       int    `yaml:"redis_db"`
+
+	RedisTLS           bool   `yaml:"redis_tls"`
+	RedisTLSCAFile     string `yaml:"redis_tls_ca_file"`
+	RedisTLSCertFile   string `yaml:"redis_tls_cert_file"`
+	RedisTLSKeyFile    string `yaml:"redis_tls_key_file"`
+	RedisTLSServerName string `yaml:"redis_tls_server_name"`
+	RedisTLSSkipVerify bool   `yaml:"redis_tls_skip_verify"`
+
+	// With RedisSentinelMaster set the engine asks the Sentinels for the
+	// current master; with RedisClusterAddrs set it talks to a cluster.
+	RedisSentinelMaster   string   `yaml:"redis_sentinel_master"`
+	RedisSentinelAddrs    []string `yaml:"redis_sentinel_addrs"`
+	RedisSentinelUsername string   `yaml:"redis_sentinel_username"`
+	RedisSentinelPassword string   `yaml:"redis_sentinel_password"`
+	RedisClusterAddrs     []string `yaml:"redis_cluster_addrs"`
+
+	// QueueName prefixes every key the engine uses, so environments can
+	// share one Redis.
+	QueueName string `yaml:"queue_name"`
+	// QueueMode is "list" or "stream". In stream mode jobs are read from a
+	// Redis Stream per lane through the consumer group QueueStreamGroup;
+	// entries left unacknowledged for QueueStreamClaimIdle are taken over
+	// by another engine and acknowledged entries are kept for
+	// QueueStreamRetention.
+	QueueMode            string        `yaml:"queue_mode"`
+	QueueStreamGroup     string        `yaml:"queue_stream_group"`
+	QueueStreamClaimIdle time.Duration `yaml:"queue_stream_claim_idle"`
+	QueueStreamRetention time.Duration `yaml:"queue_stream_retention"`
+	// In poll mode the engine claims jobs from the control plane instead
+	// of Redis, asking every PollInterval while idle. A claim not renewed
+	// for PollLease goes to another engine.
+	PollInterval time.Duration `yaml:"poll_interval"`
+	PollLease    time.Duration `yaml:"poll_lease"`
+
+	// OutboxFile journals status updates until the control plane accepts
+	// them. Each engine needs its own.
+	OutboxFile string `yaml:"outbox_file"`
+
+	// Executions on the same data source share a connection pool of at
+	// most DBMaxOpenConns connections, DBMaxIdleConns of them kept idle.
+	// Connections are replaced after DBConnMaxLifetime, or after
+	// DBConnMaxIdleTime unused.
+	DBMaxOpenConns    int           `yaml:"db_max_open_conns"`
+	DBMaxIdleConns    int           `yaml:"db_max_idle_conns"`
+	DBConnMaxLifetime time.Duration `yaml:"db_conn_max_lifetime"`
+	DBConnMaxIdleTime time.Duration `yaml:"db_conn_max_idle_time"`
+
+	// JobTimeout applies to jobs that don't set timeout_seconds.
+	JobTimeout time.Duration `yaml:"job_timeout"`
+	// StaleGrace is how long past its timeout an execution may go without
+	// a final status before the reaper settles it.
+	StaleGrace time.Duration `yaml:"stale_grace"`
+	// OutputFormat is used for report types without a format of their own;
+	// OutputRetention for reports without a retention period.
+	OutputFormat    string        `yaml:"output_format"`
+	OutputRetention time.Duration `yaml:"output_retention"`
+
+	LogLevel        string `yaml:"log_level"`
+	LogFormat       string `yaml:"log_format"`
+	TracingExporter string `yaml:"tracing_exporter"`
+	TracingFile     string `yaml:"tracing_file"`
+
+	// Concurrency caps per data source ID and per data source type
+	// (oracle, mysql, ...). Sources without a cap are only bound by
+	// WorkerCount.
+	DataSourceLimits     map[string]int `yaml:"data_source_limits"`
+	DataSourceTypeLimits map[string]int `yaml:"data_source_type_limits"`
 }
 
-func Load() *Config {
-	workers, _ := strconv.Atoi(getEnv("WORKER_COUNT", "5"))
+// Load reads the configuration from defaults, the optional YAML file named by
+// CONFIG_FILE and the environment, in increasing order of precedence, and
+// validates the result. Every problem found is reported in the error.
+func Load() (*Config, error) {
+	cfg := defaults()
+	if path := os.Getenv("CONFIG_FILE"); path != "" {
+		if err := cfg.loadFile(path); err != nil {
+			return nil, err
+		}
+	}
+
+	env := &envLoader{}
+	env.str("CONTROL_PLANE_URL", &cfg.ControlPlaneURL)
+	env.str("CONTROL_PLANE_TOKEN", &cfg.ControlPlaneToken)
+	env.duration("CONTROL_PLANE_TIMEOUT", &cfg.ControlPlaneTimeout)
+	env.int("CONTROL_PLANE_RETRIES", &cfg.ControlPlaneRetries)
+	env.int("CONTROL_PLANE_BREAKER_THRESHOLD", &cfg.ControlPlaneBreakerThreshold)
+	env.duration("CONTROL_PLANE_BREAKER_COOLDOWN", &cfg.ControlPlaneBreakerCooldown)
+	env.int("WORKER_COUNT", &cfg.WorkerCount)
+	env.str("APP_ENV", &cfg.Environment)
+	env.str("ENGINE_ID", &cfg.EngineID)
+	env.duration("ENGINE_HEARTBEAT_INTERVAL", &cfg.EngineHeartbeatInterval)
+	env.seconds("SHUTDOWN_GRACE_SECONDS", &cfg.ShutdownGrace)
+	env.str("HTTP_ADDR", &cfg.HTTPAddr)
+	env.str("REDIS_URL", &cfg.RedisURL)
+	env.str("REDIS_HOST", &cfg.RedisHost)
+	env.str("REDIS_PORT", &cfg.RedisPort)
+	env.str("REDIS_USERNAME", &cfg.RedisUsername)
+	env.str("REDIS_PASSWORD", &cfg.RedisPassword)
+	env.int("REDIS_DB", &cfg.RedisDB)
+	env.bool("REDIS_TLS", &cfg.RedisTLS)
+	env.str("REDIS_TLS_CA_FILE", &cfg.RedisTLSCAFile)
+	env.str("REDIS_TLS_CERT_FILE", &cfg.RedisTLSCertFile)
+	env.str("REDIS_TLS_KEY_FILE", &cfg.RedisTLSKeyFile)
+	env.str("REDIS_TLS_SERVER_NAME", &cfg.RedisTLSServerName)
+	env.bool("REDIS_TLS_SKIP_VERIFY", &cfg.RedisTLSSkipVerify)
+	env.str("REDIS_SENTINEL_MASTER", &cfg.RedisSentinelMaster)
+	env.list("REDIS_SENTINEL_ADDRS", &cfg.RedisSentinelAddrs)
+	env.str("REDIS_SENTINEL_USERNAME", &cfg.RedisSentinelUsername)
+	env.str("REDIS_SENTINEL_PASSWORD", &cfg.RedisSentinelPassword)
+	env.list("REDIS_CLUSTER_ADDRS", &cfg.RedisClusterAddrs)
+	env.str("QUEUE_NAME", &cfg.QueueName)
+	env.str("QUEUE_MODE", &cfg.QueueMode)
+	env.str("QUEUE_STREAM_GROUP", &cfg.QueueStreamGroup)
+	env.duration("QUEUE_STREAM_CLAIM_IDLE", &cfg.QueueStreamClaimIdle)
+	env.duration("QUEUE_STREAM_RETENTION", &cfg.QueueStreamRetention)
+	env.duration("POLL_INTERVAL", &cfg.PollInterval)
+	env.duration("POLL_LEASE", &cfg.PollLease)
+	env.str("OUTBOX_FILE", &cfg.OutboxFile)
+	env.int("DB_MAX_OPEN_CONNS", &cfg.DBMaxOpenConns)
+	env.int("DB_MAX_IDLE_CONNS", &cfg.DBMaxIdleConns)
+	env.duration("DB_CONN_MAX_LIFETIME", &cfg.DBConnMaxLifetime)
+	env.duration("DB_CONN_MAX_IDLE_TIME", &cfg.DBConnMaxIdleTime)
+	env.duration("JOB_TIMEOUT", &cfg.JobTimeout)
+	env.duration("STALE_GRACE", &cfg.StaleGrace)
+	env.str("OUTPUT_FORMAT", &cfg.OutputFormat)
+	env.duration("OUTPUT_RETENTION", &cfg.OutputRetention)
+	env.str("LOG_LEVEL", &cfg.LogLevel)
+	env.str("LOG_FORMAT", &cfg.LogFormat)
+	env.str("TRACING_EXPORTER", &cfg.TracingExporter)
+	env.str("TRACING_FILE", &cfg.TracingFile)
+	env.limits("DATA_SOURCE_LIMITS", &cfg.DataSourceLimits)
+	env.limits("DATA_SOURCE_TYPE_LIMITS", &cfg.DataSourceTypeLimits)
+	if len(env.errs) > 0 {
+		return nil, errors.Join(env.errs...)
+	}
 
+	cfg.OutputFormat = strings.ToLower(cfg.OutputFormat)
+	cfg.QueueMode = strings.ToLower(cfg.QueueMode)
+	cfg.DataSourceTypeLimits = lowerKeys(cfg.DataSourceTypeLimits)
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+func defaults() *Config {
 	return &Config{
-		ControlPlaneURL:   getEnv("CONTROL_PLANE_URL", "http://localhost:8000/api/v1"),
-		ControlPlaneToken: getEnv("CONTROL_PLANE_TOKEN", ""),
-		WorkerCount:       workers,
-		Environment:       getEnv("APP_ENV", "local"),
-		RedisHost:         getEnv("REDIS_HOST", "redis"),
-		RedisPort:         getEnv("REDIS_PORT", "6379"),
+		ControlPlaneURL:     "http://localhost:8000/api/v1",
+		ControlPlaneTimeout: 30 * time.Second,
+		WorkerCount:         5,
+		Environment:         "local",
+		EngineID:            defaultEngineID(),
+		ShutdownGrace:       30 * time.Second,
+		HTTPAddr:            ":9090",
+
+		ControlPlaneRetries:          3,
+		ControlPlaneBreakerThreshold: 5,
+		ControlPlaneBreakerCooldown:  30 * time.Second,
+
+		EngineHeartbeatInterval: 15 * time.Second,
+
+		RedisHost: "redis",
+		RedisPort: "6379",
+		QueueName: "rbdb_execution_queue",
+
+		QueueMode:            QueueModeList,
+		QueueStreamGroup:     "rbdb-engines",
+		QueueStreamClaimIdle: time.Minute,
+		QueueStreamRetention: 7 * 24 * time.Hour,
+		PollInterval:         5 * time.Second,
+		PollLease:            time.Minute,
+
+		OutboxFile: "outbox.jsonl",
+
+		DBMaxOpenConns:    10,
+		DBMaxIdleConns:    2,
+		DBConnMaxLifetime: 30 * time.Minute,
+		DBConnMaxIdleTime: 5 * time.Minute,
+
+		JobTimeout:      5 * time.Minute,
+		StaleGrace:      5 * time.Minute,
+		OutputFormat:    "csv",
+		OutputRetention: 24 * time.Hour,
+
+		LogLevel:        "info",
+		LogFormat:       "json",
+		TracingExporter: "none",
+		TracingFile:     "traces.json",
+
+		DataSourceLimits:     map[string]int{},
+		DataSourceTypeLimits: map[string]int{},
+	}
+}
+
+// loadFile overlays the keys present in a YAML file. Unknown keys are
+// rejected so typos don't go unnoticed.
+func (c *Config) loadFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("config file: %w", err)
+	}
+	defer f.Close()
+
+	dec := yaml.NewDecoder(f)
+	dec.KnownFields(true)
+	if err := dec.Decode(c); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("config file %s: %w", path, err)
+	}
+	return nil
+}
+
+// Validate checks every setting and reports all problems at once.
+func (c *Config) Validate() error {
+	var errs []error
+	fail := func(key, format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf("%s: %s", key, fmt.Sprintf(format, args...)))
+	}
+
+	if u, err := url.Parse(c.ControlPlaneURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		fail("control_plane_url", "%q is not an http(s) URL", c.ControlPlaneURL)
+	}
+	if c.ControlPlaneToken == "" {
+		fail("control_plane_token", "is required")
+	}
+	if c.ControlPlaneTimeout <= 0 {
+		fail("control_plane_timeout", "must be positive, got %s", c.ControlPlaneTimeout)
+	}
+	if c.ControlPlaneRetries < 0 || c.ControlPlaneRetries > 10 {
+		fail("control_plane_retries", "must be between 0 and 10, got %d", c.ControlPlaneRetries)
+	}
+	if c.ControlPlaneBreakerThreshold < 1 {
+		fail("control_plane_breaker_threshold", "must be at least 1, got %d", c.ControlPlaneBreakerThreshold)
+	}
+	if c.ControlPlaneBreakerCooldown < time.Second {
+		fail("control_plane_breaker_cooldown", "must be at least 1s, got %s", c.ControlPlaneBreakerCooldown)
+	}
+	if c.WorkerCount < 1 {
+		fail("worker_count", "must be at least 1, got %d", c.WorkerCount)
+	}
+	if c.EngineID == "" {
+		fail("engine_id", "must not be empty")
+	}
+	if c.EngineHeartbeatInterval < time.Second {
+		fail("engine_heartbeat_interval", "must be at least 1s, got %s", c.EngineHeartbeatInterval)
+	}
+	if c.ShutdownGrace < 0 {
+		fail("shutdown_grace", "must not be negative, got %s", c.ShutdownGrace)
+	}
+
+	c.validateRedis(fail)
+
+	if c.JobTimeout <= 0 {
+		fail("job_timeout", "must be positive, got %s", c.JobTimeout)
+	}
+	if c.OutboxFile == "" {
+		fail("outbox_file", "is required")
+	}
+	if c.DBMaxOpenConns < 1 {
+		fail("db_max_open_conns", "must be at least 1, got %d", c.DBMaxOpenConns)
+	}
+	if c.DBMaxIdleConns < 0 || c.DBMaxIdleConns > c.DBMaxOpenConns {
+		fail("db_max_idle_conns", "must be between 0 and db_max_open_conns, got %d", c.DBMaxIdleConns)
+	}
+	if c.DBConnMaxLifetime < 0 {
+		fail("db_conn_max_lifetime", "must not be negative, got %s", c.DBConnMaxLifetime)
+	}
+	if c.DBConnMaxIdleTime < 0 {
+		fail("db_conn_max_idle_time", "must not be negative, got %s", c.DBConnMaxIdleTime)
+	}
+	if c.StaleGrace < time.Minute {
+		fail("stale_grace", "must be at least 1m, got %s", c.StaleGrace)
+	}
+	if c.OutputFormat != "csv" && c.OutputFormat != "xlsx" {
+		fail("output_format", "must be csv or xlsx, got %q", c.OutputFormat)
+	}
+	if c.OutputRetention <= 0 {
+		fail("output_retention", "must be positive, got %s", c.OutputRetention)
+	}
+
+	switch strings.ToLower(c.LogLevel) {
+	case "debug", "info", "warn", "warning", "error":
+	default:
+		fail("log_level", "must be debug, info, warn or error, got %q", c.LogLevel)
+	}
+	if f := strings.ToLower(c.LogFormat); f != "json" && f != "text" {
+		fail("log_format", "must be json or text, got %q", c.LogFormat)
+	}
+	switch strings.ToLower(c.TracingExporter) {
+	case "", "none", "otlp", "stdout":
+	case "file":
+		if c.TracingFile == "" {
+			fail("tracing_file", "is required by the file exporter")
+		}
+	default:
+		fail("tracing_exporter", "must be none, otlp, stdout or file, got %q", c.TracingExporter)
+	}
+
+	validateLimits("data_source_limits", c.DataSourceLimits, fail)
+	validateLimits("data_source_type_limits", c.DataSourceTypeLimits, fail)
+	return errors.Join(errs...)
+}
+
+func (c *Config) validateRedis(fail func(key, format string, args ...interface{})) {
+	modes := 0
+	for _, set := range []bool{c.RedisURL != "", c.RedisSentinelMaster != "", len(c.RedisClusterAddrs) > 0} {
+		if set {
+			modes++
+		}
+	}
+	if modes > 1 {
+		fail("redis_url", "redis_url, redis_sentinel_master and redis_cluster_addrs are mutually exclusive")
+	}
+
+	switch {
+	case c.RedisURL != "":
+		if u, err := url.Parse(c.RedisURL); err != nil || (u.Scheme != "redis" && u.Scheme != "rediss" && u.Scheme != "unix") {
+			// Don't echo the URL: it usually holds the password.
+			fail("redis_url", "must be a redis://, rediss:// or unix:// URL")
+		}
+	case c.RedisSentinelMaster != "":
+		if len(c.RedisSentinelAddrs) == 0 {
+			fail("redis_sentinel_addrs", "required with redis_sentinel_master")
+		}
+	case len(c.RedisClusterAddrs) > 0:
+		if c.RedisDB != 0 {
+			fail("redis_db", "must be 0 with redis_cluster_addrs, clusters have a single database")
+		}
+		// Scripts and BLMOVE touch several keys at once, which a cluster
+		// only allows within one hash slot.
+		if !hasHashTag(c.QueueName) {
+			fail("queue_name", "must contain a hash tag such as {rbdb_execution_queue} with redis_cluster_addrs, got %q", c.QueueName)
+		}
+	default:
+		if c.RedisHost == "" {
+			fail("redis_host", "must not be empty")
+		}
+		if port, err := strconv.Atoi(c.RedisPort); err != nil || port < 1 || port > 65535 {
+			fail("redis_port", "%q is not a port number", c.RedisPort)
+		}
+	}
+	if len(c.RedisSentinelAddrs) > 0 && c.RedisSentinelMaster == "" {
+		fail("redis_sentinel_master", "required with redis_sentinel_addrs")
+	}
+	if c.RedisDB < 0 {
+		fail("redis_db", "must not be negative, got %d", c.RedisDB)
+	}
+
+	tlsFiles := c.RedisTLSCAFile != "" || c.RedisTLSCertFile != "" || c.RedisTLSKeyFile != ""
+	if tlsFiles && !c.RedisTLS && !strings.HasPrefix(c.RedisURL, "rediss://") {
+		fail("redis_tls", "must be enabled to use the redis_tls_* files")
+	}
+	if (c.RedisTLSCertFile == "") != (c.RedisTLSKeyFile == "") {
+		fail("redis_tls_cert_file", "and redis_tls_key_file must be set together")
+	}
+
+	if c.QueueName == "" {
+		fail("queue_name", "must not be empty")
+	}
+	switch c.QueueMode {
+	case QueueModeList:
+	case QueueModeStream:
+		if c.QueueStreamGroup == "" {
+			fail("queue_stream_group", "must not be empty")
+		}
+		// Shorter than a few heartbeats and live jobs would be stolen.
+		if c.QueueStreamClaimIdle < 30*time.Second {
+			fail("queue_stream_claim_idle", "must be at least 30s, got %s", c.QueueStreamClaimIdle)
+		}
+		if c.QueueStreamRetention < 0 {
+			fail("queue_stream_retention", "must not be negative, got %s", c.QueueStreamRetention)
+		}
+	case QueueModePoll:
+		if c.PollInterval <= 0 {
+			fail("poll_interval", "must be positive, got %s", c.PollInterval)
+		}
+		// Renewals happen every third of the lease and each may take up to
+		// the control plane timeout.
+		if c.PollLease < 30*time.Second {
+			fail("poll_lease", "must be at least 30s, got %s", c.PollLease)
+		}
+	default:
+		fail("queue_mode", "must be %q, %q or %q, got %q", QueueModeList, QueueModeStream, QueueModePoll, c.QueueMode)
+	}
+}
+
+// hasHashTag reports whether key has a non-empty {...} section, which Redis
+// Cluster hashes instead of the whole key.
+func hasHashTag(key string) bool {
+	open := strings.Index(key, "{")
+	if open < 0 {
+		return false
+	}
+	end := strings.Index(key[open+1:], "}")
+	return end > 0
+}
+
+func validateLimits(key string, limits map[string]int, fail func(key, format string, args ...interface{})) {
+	for k, n := range limits {
+		if strings.TrimSpace(k) == "" {
+			fail(key, "empty data source key")
+		}
+		if n < 1 {
+			fail(key, "limit for %q must be at least 1, got %d", k, n)
+		}
+	}
+}
+
+func lowerKeys(m map[string]int) map[string]int {
+	out := make(map[string]int, len(m))
+	for k, v := range m {
+		out[strings.ToLower(k)] = v
 	}
+	return out
 }
 
-func getEnv(key, fallback string) string {
-	if value, ok := os.LookupEnv(key); ok {
-		return value
+// defaultEngineID falls back to the hostname, which is stable per container.
+func defaultEngineID() string {
+	if host, err := os.Hostname(); err == nil && host != "" {
+		return host
 	}
-	return fallback
+	return "engine"
 }
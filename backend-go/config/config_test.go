@@ -0,0 +1,166 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "engine.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadFileWithEnvOverrides(t *testing.T) {
+	t.Setenv("CONFIG_FILE", writeFile(t, `
+control_plane_token: from-file
+worker_count: 8
+redis_port: 6380
+redis_db: 2
+queue_name: staging_execution_queue
+job_timeout: 10m
+data_source_type_limits:
+  Oracle: 3
+`))
+	t.Setenv("WORKER_COUNT", "3")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.WorkerCount != 3 {
+		t.Errorf("WorkerCount = %d, want the environment to win", cfg.WorkerCount)
+	}
+	if cfg.ControlPlaneToken != "from-file" || cfg.RedisPort != "6380" || cfg.RedisDB != 2 {
+		t.Errorf("file values not applied: %+v", cfg)
+	}
+	if cfg.QueueName != "staging_execution_queue" || cfg.JobTimeout != 10*time.Minute {
+		t.Errorf("file values not applied: %+v", cfg)
+	}
+	if cfg.DataSourceTypeLimits["oracle"] != 3 {
+		t.Errorf("type limits = %v, want lowercase keys", cfg.DataSourceTypeLimits)
+	}
+	if cfg.ShutdownGrace != 30*time.Second {
+		t.Errorf("ShutdownGrace = %s, want the default", cfg.ShutdownGrace)
+	}
+}
+
+func TestLoadReportsEveryProblem(t *testing.T) {
+	t.Setenv("CONTROL_PLANE_TOKEN", "token")
+	t.Setenv("CONFIG_FILE", writeFile(t, `
+worker_count: 0
+output_format: pdf
+log_level: verbose
+queue_mode: kafka
+`))
+
+	_, err := Load()
+	if err == nil {
+		t.Fatal("invalid configuration accepted")
+	}
+	for _, want := range []string{"worker_count", "output_format", "log_level", "queue_mode"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not mention %s", err, want)
+		}
+	}
+}
+
+func TestLoadRejectsMalformedEnv(t *testing.T) {
+	t.Setenv("CONTROL_PLANE_TOKEN", "token")
+	t.Setenv("WORKER_COUNT", "five")
+	t.Setenv("DATA_SOURCE_LIMITS", "replica=2,warehouse")
+
+	_, err := Load()
+	if err == nil {
+		t.Fatal("malformed WORKER_COUNT accepted")
+	}
+	for _, want := range []string{`WORKER_COUNT: "five"`, `DATA_SOURCE_LIMITS: "warehouse"`} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not mention %s", err, want)
+		}
+	}
+}
+
+func TestLoadRejectsUnknownFileKeys(t *testing.T) {
+	t.Setenv("CONTROL_PLANE_TOKEN", "token")
+	t.Setenv("CONFIG_FILE", writeFile(t, "worker_cuont: 4\n"))
+
+	if _, err := Load(); err == nil || !strings.Contains(err.Error(), "worker_cuont") {
+		t.Fatalf("expected an error naming the unknown key, got %v", err)
+	}
+}
+
+func TestReloadKeepsStructuralSettings(t *testing.T) {
+	current := defaults()
+	next := defaults()
+	next.WorkerCount = 9
+	next.DataSourceLimits = map[string]int{"replica": 1}
+	next.RedisHost = "other"
+	next.QueueName = "other_queue"
+
+	applied, restart := current.Reload(next)
+	if applied.WorkerCount != 9 || applied.DataSourceLimits["replica"] != 1 {
+		t.Errorf("runtime settings not applied: %+v", applied)
+	}
+	if applied.RedisHost != current.RedisHost || applied.QueueName != current.QueueName {
+		t.Errorf("structural settings changed at runtime: %+v", applied)
+	}
+	if strings.Join(restart, ",") != "redis_host,queue_name" {
+		t.Errorf("restart = %v", restart)
+	}
+	if !current.LimitsChanged(next) {
+		t.Error("LimitsChanged missed the new source limit")
+	}
+}
+
+func TestValidateRedisModes(t *testing.T) {
+	cases := []struct {
+		name  string
+		apply func(c *Config)
+		want  string
+	}{
+		{"url and sentinel", func(c *Config) {
+			c.RedisURL = "redis://redis:6379/0"
+			c.RedisSentinelMaster = "mymaster"
+			c.RedisSentinelAddrs = []string{"sentinel:26379"}
+		}, "mutually exclusive"},
+		{"bad url scheme", func(c *Config) { c.RedisURL = "http://redis:6379" }, "redis_url"},
+		{"sentinel without addrs", func(c *Config) { c.RedisSentinelMaster = "mymaster" }, "redis_sentinel_addrs"},
+		{"cluster without hash tag", func(c *Config) { c.RedisClusterAddrs = []string{"node1:6379"} }, "hash tag"},
+		{"cluster with db", func(c *Config) {
+			c.RedisClusterAddrs = []string{"node1:6379"}
+			c.QueueName = "{rbdb_execution_queue}"
+			c.RedisDB = 1
+		}, "redis_db"},
+		{"ca file without tls", func(c *Config) { c.RedisTLSCAFile = "/etc/ssl/redis-ca.pem" }, "redis_tls"},
+		{"cert without key", func(c *Config) {
+			c.RedisTLS = true
+			c.RedisTLSCertFile = "/etc/ssl/engine.pem"
+		}, "redis_tls_key_file"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := defaults()
+			cfg.ControlPlaneToken = "token"
+			tc.apply(cfg)
+			err := cfg.Validate()
+			if err == nil || !strings.Contains(err.Error(), tc.want) {
+				t.Fatalf("expected an error about %s, got %v", tc.want, err)
+			}
+		})
+	}
+
+	cfg := defaults()
+	cfg.ControlPlaneToken = "token"
+	cfg.RedisClusterAddrs = []string{"node1:6379", "node2:6379"}
+	cfg.QueueName = "{staging}:rbdb_execution_queue"
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("valid cluster configuration rejected: %v", err)
+	}
+}
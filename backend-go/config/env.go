@@ -0,0 +1,114 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// envLoader overrides settings from environment variables. Variables that
+// are set but malformed are collected in errs instead of falling back to a
+// zero value.
+type envLoader struct {
+	errs []error
+}
+
+func (e *envLoader) fail(key, value, want string) {
+	e.errs = append(e.errs, fmt.Errorf("%s: %q is not %s", key, value, want))
+}
+
+func (e *envLoader) str(key string, dst *string) {
+	if value, ok := os.LookupEnv(key); ok {
+		*dst = value
+	}
+}
+
+func (e *envLoader) int(key string, dst *int) {
+	value, ok := os.LookupEnv(key)
+	if !ok {
+		return
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil {
+		e.fail(key, value, "a whole number")
+		return
+	}
+	*dst = n
+}
+
+func (e *envLoader) bool(key string, dst *bool) {
+	value, ok := os.LookupEnv(key)
+	if !ok {
+		return
+	}
+	b, err := strconv.ParseBool(strings.TrimSpace(value))
+	if err != nil {
+		e.fail(key, value, "true or false")
+		return
+	}
+	*dst = b
+}
+
+// seconds reads a whole number of seconds.
+func (e *envLoader) seconds(key string, dst *time.Duration) {
+	if _, ok := os.LookupEnv(key); !ok {
+		return
+	}
+	n := int(*dst / time.Second)
+	e.int(key, &n)
+	*dst = time.Duration(n) * time.Second
+}
+
+// duration reads a Go duration such as "90s" or "5m".
+func (e *envLoader) duration(key string, dst *time.Duration) {
+	value, ok := os.LookupEnv(key)
+	if !ok {
+		return
+	}
+	d, err := time.ParseDuration(strings.TrimSpace(value))
+	if err != nil {
+		e.fail(key, value, "a duration such as 30s or 5m")
+		return
+	}
+	*dst = d
+}
+
+// list reads a comma-separated list such as "host1:26379,host2:26379".
+func (e *envLoader) list(key string, dst *[]string) {
+	value, ok := os.LookupEnv(key)
+	if !ok {
+		return
+	}
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	*dst = items
+}
+
+// limits reads "key=n,key=n" lists. The list replaces any limits from the
+// config file.
+func (e *envLoader) limits(key string, dst *map[string]int) {
+	value, ok := os.LookupEnv(key)
+	if !ok {
+		return
+	}
+	limits := make(map[string]int)
+	for _, entry := range strings.Split(value, ",") {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+		k, v, ok := strings.Cut(entry, "=")
+		n, err := strconv.Atoi(strings.TrimSpace(v))
+		if !ok || err != nil {
+			e.fail(key, entry, "a key=n entry")
+			continue
+		}
+		limits[strings.TrimSpace(k)] = n
+	}
+	*dst = limits
+}
@@ -0,0 +1,85 @@
+package config
+
+import (
+	"maps"
+	"slices"
+)
+
+// Reload returns a copy of c with the settings that can change at runtime
+// taken from next: worker count, shutdown grace, job timeout, stale grace,
+// output defaults, log level, data source limits and connection pool
+// sizes. It also returns the keys of the other settings that differ in
+// next; those only apply after a restart.
+func (c *Config) Reload(next *Config) (*Config, []string) {
+	applied := *c
+	applied.WorkerCount = next.WorkerCount
+	applied.ShutdownGrace = next.ShutdownGrace
+	applied.JobTimeout = next.JobTimeout
+	applied.StaleGrace = next.StaleGrace
+	applied.OutputFormat = next.OutputFormat
+	applied.OutputRetention = next.OutputRetention
+	applied.LogLevel = next.LogLevel
+	applied.DataSourceLimits = next.DataSourceLimits
+	applied.DataSourceTypeLimits = next.DataSourceTypeLimits
+	applied.DBMaxOpenConns = next.DBMaxOpenConns
+	applied.DBMaxIdleConns = next.DBMaxIdleConns
+	applied.DBConnMaxLifetime = next.DBConnMaxLifetime
+	applied.DBConnMaxIdleTime = next.DBConnMaxIdleTime
+
+	structural := []struct {
+		key     string
+		changed bool
+	}{
+		{"control_plane_url", c.ControlPlaneURL != next.ControlPlaneURL},
+		{"control_plane_token", c.ControlPlaneToken != next.ControlPlaneToken},
+		{"control_plane_timeout", c.ControlPlaneTimeout != next.ControlPlaneTimeout},
+		{"control_plane_retries", c.ControlPlaneRetries != next.ControlPlaneRetries},
+		{"control_plane_breaker_threshold", c.ControlPlaneBreakerThreshold != next.ControlPlaneBreakerThreshold},
+		{"control_plane_breaker_cooldown", c.ControlPlaneBreakerCooldown != next.ControlPlaneBreakerCooldown},
+		{"app_env", c.Environment != next.Environment},
+		{"engine_id", c.EngineID != next.EngineID},
+		{"engine_heartbeat_interval", c.EngineHeartbeatInterval != next.EngineHeartbeatInterval},
+		{"http_addr", c.HTTPAddr != next.HTTPAddr},
+		{"redis_url", c.RedisURL != next.RedisURL},
+		{"redis_host", c.RedisHost != next.RedisHost},
+		{"redis_port", c.RedisPort != next.RedisPort},
+		{"redis_username", c.RedisUsername != next.RedisUsername},
+		{"redis_password", c.RedisPassword != next.RedisPassword},
+		{"redis_db", c.RedisDB != next.RedisDB},
+		{"redis_tls", c.RedisTLS != next.RedisTLS},
+		{"redis_tls_ca_file", c.RedisTLSCAFile != next.RedisTLSCAFile},
+		{"redis_tls_cert_file", c.RedisTLSCertFile != next.RedisTLSCertFile},
+		{"redis_tls_key_file", c.RedisTLSKeyFile != next.RedisTLSKeyFile},
+		{"redis_tls_server_name", c.RedisTLSServerName != next.RedisTLSServerName},
+		{"redis_tls_skip_verify", c.RedisTLSSkipVerify != next.RedisTLSSkipVerify},
+		{"redis_sentinel_master", c.RedisSentinelMaster != next.RedisSentinelMaster},
+		{"redis_sentinel_addrs", !slices.Equal(c.RedisSentinelAddrs, next.RedisSentinelAddrs)},
+		{"redis_sentinel_username", c.RedisSentinelUsername != next.RedisSentinelUsername},
+		{"redis_sentinel_password", c.RedisSentinelPassword != next.RedisSentinelPassword},
+		{"redis_cluster_addrs", !slices.Equal(c.RedisClusterAddrs, next.RedisClusterAddrs)},
+		{"queue_name", c.QueueName != next.QueueName},
+		{"queue_mode", c.QueueMode != next.QueueMode},
+		{"queue_stream_group", c.QueueStreamGroup != next.QueueStreamGroup},
+		{"queue_stream_claim_idle", c.QueueStreamClaimIdle != next.QueueStreamClaimIdle},
+		{"queue_stream_retention", c.QueueStreamRetention != next.QueueStreamRetention},
+		{"poll_interval", c.PollInterval != next.PollInterval},
+		{"poll_lease", c.PollLease != next.PollLease},
+		{"log_format", c.LogFormat != next.LogFormat},
+		{"tracing_exporter", c.TracingExporter != next.TracingExporter},
+		{"tracing_file", c.TracingFile != next.TracingFile},
+		{"outbox_file", c.OutboxFile != next.OutboxFile},
+	}
+	var restart []string
+	for _, s := range structural {
+		if s.changed {
+			restart = append(restart, s.key)
+		}
+	}
+	return &applied, restart
+}
+
+// LimitsChanged reports whether next caps data sources differently from c.
+func (c *Config) LimitsChanged(next *Config) bool {
+	return !maps.Equal(c.DataSourceLimits, next.DataSourceLimits) ||
+		!maps.Equal(c.DataSourceTypeLimits, next.DataSourceTypeLimits)
+}
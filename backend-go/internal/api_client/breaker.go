@@ -0,0 +1,123 @@
+package api_client
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"rbdb-backend-go/internal/metrics"
+)
+
+// ErrCircuitOpen is returned without contacting the control plane while the
+// circuit breaker is open.
+var ErrCircuitOpen = fmt.Errorf("%w: circuit breaker open", ErrUnavailable)
+
+// breaker stops calls to the control plane after threshold consecutive
+// failures. Once cooldown has passed it lets one call through as a probe:
+// if that succeeds calls resume, otherwise the breaker opens again.
+type breaker struct {
+	threshold int
+	cooldown  time.Duration
+
+	mu       sync.Mutex
+	failures int
+	openedAt time.Time
+	open     bool
+	probing  bool
+	// closed is closed when the breaker closes, waking WaitAvailable.
+	closed chan struct{}
+}
+
+func newBreaker(threshold int, cooldown time.Duration) *breaker {
+	return &breaker{threshold: threshold, cooldown: cooldown, closed: make(chan struct{})}
+}
+
+// allow reports whether a call may go out, taking the probe slot if the
+// cooldown has passed.
+func (b *breaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.open {
+		return true
+	}
+	if b.probing || time.Since(b.openedAt) < b.cooldown {
+		return false
+	}
+	b.probing = true
+	return true
+}
+
+// record counts the outcome of an allowed call. Only unavailability counts
+// as a failure; any answer, even an error status, shows the control plane
+// is up.
+func (b *breaker) record(failed bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.probing = false
+	if !failed {
+		b.failures = 0
+		if b.open {
+			b.open = false
+			close(b.closed)
+			metrics.CircuitOpen.Set(0)
+			slog.Info("Control plane reachable again, circuit breaker closed")
+		}
+		return
+	}
+
+	b.failures++
+	if b.open || b.failures >= b.threshold {
+		if !b.open {
+			b.open = true
+			b.closed = make(chan struct{})
+			metrics.CircuitOpen.Set(1)
+			slog.Warn("Control plane unavailable, circuit breaker opened", "failures", b.failures, "cooldown", b.cooldown.String())
+		}
+		b.openedAt = time.Now()
+	}
+}
+
+// abandon gives back the probe slot of a call whose context ended before it
+// had an outcome.
+func (b *breaker) abandon() {
+	b.mu.Lock()
+	b.probing = false
+	b.mu.Unlock()
+}
+
+// available reports whether the breaker is closed or its cooldown passed.
+func (b *breaker) available() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return !b.open || time.Since(b.openedAt) >= b.cooldown
+}
+
+// wait blocks until the breaker closes or lets a probe through, or ctx is
+// done.
+func (b *breaker) wait(ctx context.Context) error {
+	for {
+		b.mu.Lock()
+		if !b.open {
+			b.mu.Unlock()
+			return nil
+		}
+		closed := b.closed
+		retryIn := b.cooldown - time.Since(b.openedAt)
+		b.mu.Unlock()
+		if retryIn <= 0 {
+			return nil
+		}
+
+		timer := time.NewTimer(retryIn)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-closed:
+		case <-timer.C:
+		}
+		timer.Stop()
+	}
+}
@@ -0,0 +1,78 @@
+package api_client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+)
+
+// ErrClaimLost is returned when the control plane no longer holds a claim
+// for the caller, because it expired and was handed to another engine or
+// the execution was settled.
+var ErrClaimLost = errors.New("execution claim lost")
+
+// Claim is a job the control plane handed to an engine for a limited time.
+type Claim struct {
+	ExecutionID    string          `json:"execution_id"`
+	Token          string          `json:"token"`
+	Payload        json.RawMessage `json:"payload"`
+	LeaseExpiresAt time.Time       `json:"lease_expires_at"`
+}
+
+// ClaimRelease settles a claim. Done drops the job; otherwise it becomes
+// available again at AvailableAt, or right away, with Payload replacing
+// the stored job when set.
+type ClaimRelease struct {
+	Token       string          `json:"token"`
+	Done        bool            `json:"done,omitempty"`
+	Payload     json.RawMessage `json:"payload,omitempty"`
+	AvailableAt *time.Time      `json:"available_at,omitempty"`
+}
+
+// ClaimExecutions takes up to limit queued jobs for engineID, each held for
+// lease unless renewed.
+func (c *Client) ClaimExecutions(ctx context.Context, engineID string, limit int, lease time.Duration) ([]Claim, error) {
+	var claims []Claim
+	err := c.call(ctx, "claim_executions", func(ctx context.Context) error {
+		body := map[string]interface{}{
+			"engine_id":     engineID,
+			"limit":         limit,
+			"lease_seconds": int(lease.Seconds()),
+		}
+		return claimError(c.post(ctx, "/executions/claim", body, &claims))
+	})
+	return claims, err
+}
+
+// RenewClaim extends a claim by lease. It reports whether an operator asked
+// to cancel the execution in the meantime.
+func (c *Client) RenewClaim(ctx context.Context, executionID, token string, lease time.Duration) (bool, error) {
+	var state struct {
+		Cancelled bool `json:"cancelled"`
+	}
+	err := c.call(ctx, "renew_claim", func(ctx context.Context) error {
+		body := map[string]interface{}{
+			"token":         token,
+			"lease_seconds": int(lease.Seconds()),
+		}
+		return claimError(c.post(ctx, "/executions/"+executionID+"/claim/renew", body, &state))
+	})
+	return state.Cancelled, err
+}
+
+// ReleaseClaim settles a claim as described by release.
+func (c *Client) ReleaseClaim(ctx context.Context, executionID string, release ClaimRelease) error {
+	return c.call(ctx, "release_claim", func(ctx context.Context) error {
+		return claimError(c.post(ctx, "/executions/"+executionID+"/claim/release", release, nil))
+	})
+}
+
+// claimError maps the responses for a claim that is gone to ErrClaimLost.
+func claimError(err error) error {
+	if code := statusOf(err); code == http.StatusConflict || code == http.StatusNotFound {
+		return ErrClaimLost
+	}
+	return err
+}
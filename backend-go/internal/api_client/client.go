@@ -2,11 +2,17 @@ package api_client
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"log/slog"
 	"net/http"
 	"rbdb-backend-go/config"
+	"rbdb-backend-go/internal/metrics"
 	"rbdb-backend-go/internal/models"
+	"rbdb-backend-go/internal/tracing"
 	"time"
 )
 
@@ -14,115 +20,184 @@ type Client struct {
 	BaseURL string
 	Token   string
 	HTTP    *http.Client
+	// Retries is how many times a request is sent again after a failed
+	// connection or a 408, 429 or 5xx response.
+	Retries int
+
+	breaker *breaker
 }
 
 func NewClient(cfg *config.Config) *Client {
 	return &Client{
 		BaseURL: cfg.ControlPlaneURL,
 		Token:   cfg.ControlPlaneToken,
-		HTTP:    &http.Client{Timeout: 30 * time.Second},
+		HTTP:    &http.Client{Timeout: cfg.ControlPlaneTimeout},
+		Retries: cfg.ControlPlaneRetries,
+		breaker: newBreaker(cfg.ControlPlaneBreakerThreshold, cfg.ControlPlaneBreakerCooldown),
 	}
 }
 
-func (c *Client) GetReport(reportID string) (*models.Report, error) {
-	url := fmt.Sprintf("%s/reports/%s", c.BaseURL, reportID)
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		return nil, err
+// GetReport loads a report definition. A report the control plane doesn't
+// have is reported as ErrNotFound.
+func (c *Client) GetReport(ctx context.Context, reportID string) (*models.Report, error) {
+	var report *models.Report
+	err := c.call(ctx, "get_report", func(ctx context.Context) error {
+		return c.do(ctx, http.MethodGet, "/reports/"+reportID, nil, &report)
+	})
+	if err == nil && report == nil {
+		err = fmt.Errorf("report %s: %w", reportID, ErrNotFound)
 	}
-	
-	c.addHeaders(req)
-	
-	resp, err := c.HTTP.Do(req)
-	if err != nil {
-		return nil, err
+	return report, err
+}
+
+// UpdateExecution reports an execution's status. Callers pass a context
+// that outlives the job's, so final updates still go out after a job has
+// been cancelled.
+func (c *Client) UpdateExecution(ctx context.Context, executionID string, update models.ExecutionUpdate) error {
+	return c.call(ctx, "update_execution", func(ctx context.Context) error {
+		return c.do(ctx, http.MethodPut, "/executions/"+executionID, update, nil)
+	})
+}
+
+// Ping checks that the control plane answers its liveness endpoint. It
+// bypasses retries and the circuit breaker, so it always shows the current
+// state.
+func (c *Client) Ping(ctx context.Context) error {
+	err := c.send(ctx, http.MethodGet, "/health/live", nil, nil)
+	countError("ping", err)
+	return err
+}
+
+// Available reports whether calls go out: the circuit breaker is closed,
+// or its cooldown has passed and a probe may be sent.
+func (c *Client) Available() bool {
+	return c.breaker == nil || c.breaker.available()
+}
+
+// WaitAvailable blocks until Available would return true or ctx is done.
+func (c *Client) WaitAvailable(ctx context.Context) error {
+	if c.breaker == nil {
+		return nil
 	}
-	defer resp.Body.Close()
-	
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("failed to fetch report, status: %d", resp.StatusCode)
+	return c.breaker.wait(ctx)
+}
+
+func (c *Client) post(ctx context.Context, path string, body, out interface{}) error {
+	return c.do(ctx, http.MethodPost, path, body, out)
+}
+
+// do sends a request through the circuit breaker, retrying it as described
+// for Retries, and decodes the data field of the response into out, if
+// given. Responses other than 2xx are returned as a *StatusError.
+func (c *Client) do(ctx context.Context, method, path string, body, out interface{}) error {
+	var data []byte
+	if body != nil {
+		var err error
+		if data, err = json.Marshal(body); err != nil {
+			return err
+		}
 	}
 
-	var parsedResp struct {
-		Success bool           `json:"success"`
-		Data    *models.Report `json:"data"`
+	if c.breaker != nil && !c.breaker.allow() {
+		return ErrCircuitOpen
 	}
-	
-	if err := json.NewDecoder(resp.Body).Decode(&parsedResp); err != nil {
-		return nil, err
+	err := c.retry(ctx, method, path, data, out)
+	if c.breaker != nil {
+		if ctx.Err() != nil {
+			c.breaker.abandon()
+		} else {
+			c.breaker.record(errors.Is(err, ErrUnavailable))
+		}
 	}
-	
-	return parsedResp.Data, nil
+	return err
 }
 
-func (c *Client) GetPendingExecutions() ([]models.Job, error) {
-    // This assumes the Control Plane has this endpoint. 
-    // If not, this will return 404.
-    url := fmt.Sprintf("%s/executions?status=pending", c.BaseURL)
-    req, err := http.NewRequest("GET", url, nil)
-    if err != nil {
-        return nil, err
-    }
-    c.addHeaders(req)
-    
-    resp, err := c.HTTP.Do(req)
-    if err != nil {
-        return nil, err
-    }
-    defer resp.Body.Close()
-    
-    // If API not ready, return empty list gracefully to avoid crash loop
-    if resp.StatusCode != http.StatusOK {
-        return []models.Job{}, nil // fmt.Errorf("failed to fetch executions, status: %d", resp.StatusCode)
-    }
-
-    var parsedResp struct {
-        Success bool         `json:"success"`
-        Data    []models.Job `json:"data"`
-    }
-    if err := json.NewDecoder(resp.Body).Decode(&parsedResp); err != nil {
-       return nil, err
-    }
-    return parsedResp.Data, nil
+// retry sends a request until it succeeds, fails for good or runs out of
+// retries. The wait between attempts is a jittered backoff, or what the
+// control plane asked for with Retry-After.
+func (c *Client) retry(ctx context.Context, method, path string, data []byte, out interface{}) error {
+	for attempt := 0; ; attempt++ {
+		err := c.send(ctx, method, path, data, out)
+		if err == nil || attempt >= c.Retries || ctx.Err() != nil || !errors.Is(err, ErrUnavailable) {
+			return err
+		}
+
+		delay := backoff(attempt)
+		var se *StatusError
+		if errors.As(err, &se) && se.retryAfter > 0 {
+			if se.retryAfter > maxRetryAfter {
+				return err
+			}
+			delay = se.retryAfter
+		}
+		slog.Debug("Retrying control plane request", "method", method, "path", path, "attempt", attempt+1, "delay", delay.String(), "error", err)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
 }
 
-func (c *Client) UpdateExecution(executionID string, update models.ExecutionUpdate) error {
-	// Assumption: Endpoint exists at /executions/{id}. 
-    // Wait, I didn't verify ExecutionController in Phase 2.5, I only did CRUD for core resources.
-    // However, I should assume a standard structure. If it doesn't exist, I'll need to mock it or ask user to create it locally?
-    // User said "Update execution status in Control Plane executions table".
-    // I likely need to assume this endpoint exists or will be created. 
-    // Since Phase 2.5 was "Control Plane Completion", and I didn't explicitly implement ExecutionController logic (it was not requested in Phase 2.5 explicitly, only core metadata).
-    // I can assume standard REST: PUT /api/v1/executions/{id}
-    
-	url := fmt.Sprintf("%s/executions/%s", c.BaseURL, executionID)
-	
-	body, err := json.Marshal(update)
-	if err != nil {
-		return err
+// send makes one request. Failed connections are returned wrapped in
+// ErrUnavailable.
+func (c *Client) send(ctx context.Context, method, path string, data []byte, out interface{}) error {
+	var body io.Reader
+	if data != nil {
+		body = bytes.NewReader(data)
 	}
-	
-	req, err := http.NewRequest("PUT", url, bytes.NewBuffer(body))
+	req, err := http.NewRequestWithContext(ctx, method, c.BaseURL+path, body)
 	if err != nil {
 		return err
 	}
-	
-	c.addHeaders(req)
-	
+	c.addHeaders(ctx, req)
+
 	resp, err := c.HTTP.Do(req)
 	if err != nil {
-		return err
+		if ctx.Err() != nil {
+			return err
+		}
+		return fmt.Errorf("%w: %w", ErrUnavailable, err)
 	}
 	defer resp.Body.Close()
-	
-	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
-		return fmt.Errorf("failed to update execution, status: %d", resp.StatusCode)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		se := newStatusError(resp, path)
+		se.retryAfter = parseRetryAfter(resp.Header.Get("Retry-After"))
+		return se
+	}
+	if out == nil || resp.StatusCode == http.StatusNoContent {
+		return nil
+	}
+
+	parsed := struct {
+		Data interface{} `json:"data"`
+	}{Data: out}
+	return json.NewDecoder(resp.Body).Decode(&parsed)
+}
+
+// call runs one control plane operation inside a client span and counts
+// its failures.
+func (c *Client) call(ctx context.Context, operation string, fn func(context.Context) error) error {
+	ctx, span := tracing.StartClient(ctx, "control_plane."+operation)
+	err := fn(ctx)
+	tracing.End(span, err)
+	countError(operation, err)
+	return err
+}
+
+// countError records failed calls in the control plane error metric.
+func countError(operation string, err error) {
+	if err != nil {
+		metrics.APIErrors.WithLabelValues(operation).Inc()
 	}
-	
-	return nil
 }
 
-func (c *Client) addHeaders(req *http.Request) {
+func (c *Client) addHeaders(ctx context.Context, req *http.Request) {
+	tracing.Inject(ctx, req.Header)
 	req.Header.Set("Authorization", "Bearer "+c.Token)
 	req.Header.Set("Accept", "application/json")
 	req.Header.Set("Content-Type", "application/json")
@@ -0,0 +1,136 @@
+package api_client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"rbdb-backend-go/internal/models"
+)
+
+// newTestClient serves every request with handle and counts them.
+func newTestClient(t *testing.T, handle func(w http.ResponseWriter, n int32)) (*Client, *atomic.Int32) {
+	t.Helper()
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handle(w, calls.Add(1))
+	}))
+	t.Cleanup(srv.Close)
+	return &Client{BaseURL: srv.URL, HTTP: srv.Client(), Retries: 3}, &calls
+}
+
+func TestRetriesUnavailableHonouringRetryAfter(t *testing.T) {
+	c, calls := newTestClient(t, func(w http.ResponseWriter, n int32) {
+		if n == 1 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"success":true,"data":{"id":"report-1","name":"Sales"}}`))
+	})
+
+	report, err := c.GetReport(context.Background(), "report-1")
+	if err != nil {
+		t.Fatalf("get report: %v", err)
+	}
+	if report.Name != "Sales" || calls.Load() != 2 {
+		t.Fatalf("expected the report after one retry, got %+v after %d calls", report, calls.Load())
+	}
+}
+
+func TestTypedErrorsAreNotRetried(t *testing.T) {
+	tests := []struct {
+		status int
+		want   error
+	}{
+		{http.StatusNotFound, ErrNotFound},
+		{http.StatusUnauthorized, ErrUnauthorized},
+		{http.StatusUnprocessableEntity, ErrValidation},
+	}
+
+	for _, tt := range tests {
+		t.Run(http.StatusText(tt.status), func(t *testing.T) {
+			c, calls := newTestClient(t, func(w http.ResponseWriter, n int32) {
+				w.WriteHeader(tt.status)
+				w.Write([]byte(`{"success":false,"message":"Nope","error":{"status":["invalid"]}}`))
+			})
+
+			err := c.UpdateExecution(context.Background(), "exec-1", models.ExecutionUpdate{Status: "done"})
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("expected %v, got %v", tt.want, err)
+			}
+			if errors.Is(err, ErrUnavailable) || calls.Load() != 1 {
+				t.Fatalf("expected a single call, got %d", calls.Load())
+			}
+			if !strings.Contains(err.Error(), "Nope") {
+				t.Fatalf("expected the control plane's message in %q", err)
+			}
+		})
+	}
+}
+
+func TestReportNotFoundWhenDataIsEmpty(t *testing.T) {
+	c, _ := newTestClient(t, func(w http.ResponseWriter, n int32) {
+		w.Write([]byte(`{"success":true,"data":null}`))
+	})
+	if _, err := c.GetReport(context.Background(), "report-1"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	var up atomic.Bool
+	c, calls := newTestClient(t, func(w http.ResponseWriter, n int32) {
+		if !up.Load() {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.Write([]byte(`{"success":true,"data":null}`))
+	})
+	c.Retries = 0
+	c.breaker = newBreaker(2, 50*time.Millisecond)
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		if err := c.post(ctx, "/ping", nil, nil); !errors.Is(err, ErrUnavailable) {
+			t.Fatalf("expected ErrUnavailable, got %v", err)
+		}
+	}
+	if err := c.post(ctx, "/ping", nil, nil); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("expected the breaker to be open, got %v", err)
+	}
+	if calls.Load() != 2 || c.Available() {
+		t.Fatalf("expected no call while open, got %d", calls.Load())
+	}
+
+	up.Store(true)
+	waitCtx, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+	if err := c.WaitAvailable(waitCtx); err != nil {
+		t.Fatalf("breaker never let a probe through: %v", err)
+	}
+	if err := c.post(ctx, "/ping", nil, nil); err != nil {
+		t.Fatalf("probe: %v", err)
+	}
+	if err := c.post(ctx, "/ping", nil, nil); err != nil {
+		t.Fatalf("expected the breaker to be closed, got %v", err)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	if got := parseRetryAfter("7"); got != 7*time.Second {
+		t.Errorf("seconds: got %s", got)
+	}
+	date := time.Now().Add(time.Hour).UTC().Format(http.TimeFormat)
+	if got := parseRetryAfter(date); got < 59*time.Minute || got > time.Hour {
+		t.Errorf("date: got %s", got)
+	}
+	if got := parseRetryAfter("soon"); got != 0 {
+		t.Errorf("invalid: got %s", got)
+	}
+}
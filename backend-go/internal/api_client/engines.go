@@ -0,0 +1,30 @@
+package api_client
+
+import (
+	"context"
+	"errors"
+
+	"rbdb-backend-go/internal/models"
+)
+
+// ErrEngineUnknown is returned for heartbeats of an engine the control plane
+// has no record of; the engine has to register again.
+var ErrEngineUnknown = errors.New("engine not registered")
+
+// RegisterEngine announces the engine and its capabilities.
+func (c *Client) RegisterEngine(ctx context.Context, reg models.EngineRegistration) error {
+	return c.call(ctx, "register_engine", func(ctx context.Context) error {
+		return c.post(ctx, "/engines/register", reg, nil)
+	})
+}
+
+// SendHeartbeat reports the engine's state.
+func (c *Client) SendHeartbeat(ctx context.Context, engineID string, hb models.EngineHeartbeat) error {
+	return c.call(ctx, "engine_heartbeat", func(ctx context.Context) error {
+		err := c.post(ctx, "/engines/"+engineID+"/heartbeat", hb, nil)
+		if errors.Is(err, ErrNotFound) {
+			return ErrEngineUnknown
+		}
+		return err
+	})
+}
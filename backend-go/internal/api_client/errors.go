@@ -0,0 +1,92 @@
+package api_client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// Errors callers can check with errors.Is. A *StatusError matches the one
+// for its status code; failed connections and circuit breaker rejections
+// match ErrUnavailable.
+var (
+	ErrNotFound     = errors.New("control plane: not found")
+	ErrUnauthorized = errors.New("control plane: unauthorized")
+	ErrValidation   = errors.New("control plane: request rejected")
+	ErrUnavailable  = errors.New("control plane: unavailable")
+)
+
+// StatusError is a response with an unexpected status code.
+type StatusError struct {
+	Method string
+	Path   string
+	Code   int
+	// Message is the error the control plane gave, if any.
+	Message string
+
+	retryAfter time.Duration
+}
+
+func (e *StatusError) Error() string {
+	if e.Message != "" {
+		return fmt.Sprintf("%s %s returned status %d: %s", e.Method, e.Path, e.Code, e.Message)
+	}
+	return fmt.Sprintf("%s %s returned status %d", e.Method, e.Path, e.Code)
+}
+
+func (e *StatusError) Is(target error) bool {
+	switch target {
+	case ErrNotFound:
+		return e.Code == http.StatusNotFound
+	case ErrUnauthorized:
+		return e.Code == http.StatusUnauthorized || e.Code == http.StatusForbidden
+	case ErrValidation:
+		return e.Code == http.StatusBadRequest || e.Code == http.StatusUnprocessableEntity
+	case ErrUnavailable:
+		return retryableStatus(e.Code)
+	}
+	return false
+}
+
+// newStatusError reads the control plane's error message from resp.
+func newStatusError(resp *http.Response, path string) *StatusError {
+	e := &StatusError{Method: resp.Request.Method, Path: path, Code: resp.StatusCode}
+	var body struct {
+		Message string          `json:"message"`
+		Error   json.RawMessage `json:"error"`
+	}
+	if json.NewDecoder(io.LimitReader(resp.Body, 64*1024)).Decode(&body) == nil {
+		e.Message = body.Message
+		// Validation errors list the offending fields.
+		if e.Code == http.StatusUnprocessableEntity && len(body.Error) > 0 {
+			e.Message += " " + string(body.Error)
+		}
+	}
+	return e
+}
+
+// statusOf returns the status code carried by err, or 0.
+func statusOf(err error) int {
+	var se *StatusError
+	if errors.As(err, &se) {
+		return se.Code
+	}
+	return 0
+}
+
+// retryableStatus reports whether a response with code may succeed if the
+// request is sent again.
+func retryableStatus(code int) bool {
+	return code >= 500 || code == http.StatusTooManyRequests || code == http.StatusRequestTimeout
+}
+
+// IsPermanent reports whether err is a rejection that sending the same
+// request again won't change: a 4xx response other than 408 and 429, or
+// 401 and 403, which a corrected token fixes.
+func IsPermanent(err error) bool {
+	code := statusOf(err)
+	return code >= 400 && code < 500 && !retryableStatus(code) && !errors.Is(err, ErrUnauthorized)
+}
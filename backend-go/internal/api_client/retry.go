@@ -0,0 +1,47 @@
+package api_client
+
+import (
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	// Retries back off from retryBase, doubling per attempt up to retryCap,
+	// with full jitter so engines don't retry in lockstep.
+	retryBase = 250 * time.Millisecond
+	retryCap  = 10 * time.Second
+	// maxRetryAfter is the longest Retry-After a request waits for; longer
+	// ones fail the request right away.
+	maxRetryAfter = time.Minute
+)
+
+// backoff returns the wait before retry number attempt+1.
+func backoff(attempt int) time.Duration {
+	limit := retryCap
+	if attempt < 16 {
+		limit = min(retryBase<<attempt, retryCap)
+	}
+	return rand.N(limit) + 1
+}
+
+// parseRetryAfter reads a Retry-After header given in seconds or as an
+// HTTP date. It returns 0 if the header is missing or invalid.
+func parseRetryAfter(v string) time.Duration {
+	if v == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(v); err == nil {
+		if secs < 0 {
+			return 0
+		}
+		return time.Duration(secs) * time.Second
+	}
+	if at, err := http.ParseTime(v); err == nil {
+		if d := time.Until(at); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
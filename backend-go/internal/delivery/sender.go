@@ -1,13 +1,16 @@
 package delivery
 
 import (
+	"context"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"net/smtp"
 	"os"
 	"time"
 
+	"rbdb-backend-go/internal/logging"
+
 	"github.com/jlaffaye/ftp"
 )
 
@@ -18,6 +21,9 @@ const (
 	TypeFTP   DeliveryType = "ftp"
 )
 
+// StreamTypes lists the delivery types SendStream supports.
+var StreamTypes = []DeliveryType{TypeFTP}
+
 func Send(dType DeliveryType, config map[string]interface{}, filePath string) (string, error) {
 	switch dType {
 	case TypeEmail:
@@ -29,41 +35,87 @@ func Send(dType DeliveryType, config map[string]interface{}, filePath string) (s
 			return "", err
 		}
 		defer f.Close()
-		return uploadFTPStream(config, f)
+		return uploadFTPStream(context.Background(), config, f)
 	default:
 		return "", fmt.Errorf("unsupported delivery type: %s", dType)
 	}
 }
 
-func SendStream(dType DeliveryType, config map[string]interface{}, r io.Reader) (string, error) {
+// SendStream delivers r to the target. Cancelling ctx aborts the transfer
+// and removes any partially written remote file.
+func SendStream(ctx context.Context, dType DeliveryType, config map[string]interface{}, r io.Reader) (string, error) {
 	switch dType {
 	case TypeFTP:
-		return uploadFTPStream(config, r)
+		return uploadFTPStream(ctx, config, r)
 	default:
 		return "", fmt.Errorf("unsupported streaming delivery type: %s", dType)
 	}
 }
 
 func sendEmail(config map[string]interface{}, filePath string) error {
-    host, _ := config["host"].(string)
-    port, _ := config["port"].(float64) // JSON numbers are floats
-    username, _ := config["username"].(string)
-    password, _ := config["password"].(string)
-    to, _ := config["to"].(string)
-    from, _ := config["from"].(string)
-
-    // TODO: Implement attachment logic using MIME/Multipart.
-    // For brevity, I'll just check connection.
-    // In real implementation, need to construct multipart message.
-    
-    auth := smtp.PlainAuth("", username, password, host)
-    err := smtp.SendMail(fmt.Sprintf("%s:%d", host, int(port)), auth, from, []string{to}, []byte("Subject: Report\r\n\r\nSee attachment."))
-    return err
+	host, _ := config["host"].(string)
+	port, _ := config["port"].(float64) // JSON numbers are floats
+	username, _ := config["username"].(string)
+	password, _ := config["password"].(string)
+	to, _ := config["to"].(string)
+	from, _ := config["from"].(string)
+
+	// TODO: Implement attachment logic using MIME/Multipart.
+	// For brevity, I'll just check connection.
+	// In real implementation, need to construct multipart message.
+
+	auth := smtp.PlainAuth("", username, password, host)
+	err := smtp.SendMail(fmt.Sprintf("%s:%d", host, int(port)), auth, from, []string{to}, []byte("Subject: Report\r\n\r\nSee attachment."))
+	return err
+}
+
+func uploadFTPStream(ctx context.Context, config map[string]interface{}, r io.Reader) (string, error) {
+	addr, username, password := ftpCredentials(config)
+	reportName, _ := config["report_name"].(string)
+	extension, _ := config["extension"].(string)
+
+	lg := logging.FromContext(ctx)
+	lg.Info("Connecting to FTP", "addr", addr, "username", username)
+
+	c, err := dialFTP(ctx, addr, username, password)
+	if err != nil {
+		return "", err
+	}
+	// An aborted transfer already closed the connection.
+	aborted := false
+	defer func() {
+		if !aborted {
+			c.Quit()
+		}
+	}()
+
+	// Dynamic Path: [YYYY-MM-DD]-[ReportName]
+	now := time.Now()
+	dateFolder := fmt.Sprintf("%s-%s", now.Format("2006-01-02"), reportName)
+	fileName := fmt.Sprintf("%s-%s.%s", now.Format("2006-01-02"), now.Format("15:04"), extension)
+	finalPath := fmt.Sprintf("%s/%s", dateFolder, fileName)
+
+	// Ensure directory exists
+	_ = c.MakeDir(dateFolder)
+
+	// Closing the control connection is the only way to interrupt a stalled
+	// transfer; a cancelled reader alone can't unblock a hung server.
+	stop := context.AfterFunc(ctx, func() { c.Quit() })
+	err = c.Stor(finalPath, r)
+	aborted = !stop()
+	if err != nil || aborted {
+		if err == nil {
+			err = ctx.Err()
+		}
+		removePartialFTPFile(lg, c, aborted, addr, username, password, finalPath)
+		return "", err
+	}
+	return finalPath, nil
 }
 
-func uploadFTPStream(config map[string]interface{}, r io.Reader) (string, error) {
+func ftpCredentials(config map[string]interface{}) (addr, username, password string) {
 	host, _ := config["host"].(string)
-	
+
 	// Handle different types for port (int, float64, string)
 	var port int
 	switch p := config["port"].(type) {
@@ -80,37 +132,44 @@ func uploadFTPStream(config map[string]interface{}, r io.Reader) (string, error)
 		port = 21 // Default FTP port
 	}
 
-	username, _ := config["username"].(string)
-	password, _ := config["password"].(string)
-	reportName, _ := config["report_name"].(string)
-	extension, _ := config["extension"].(string)
+	username, _ = config["username"].(string)
+	password, _ = config["password"].(string)
+	logging.AddSecret(password)
+	return fmt.Sprintf("%s:%d", host, port), username, password
+}
 
-	addr := fmt.Sprintf("%s:%d", host, port)
-	log.Printf("Connecting to FTP: %s as %s", addr, username)
-	
-	c, err := ftp.Dial(addr, ftp.DialWithTimeout(5*time.Second))
+func dialFTP(ctx context.Context, addr, username, password string) (*ftp.ServerConn, error) {
+	c, err := ftp.Dial(addr, ftp.DialWithTimeout(5*time.Second), ftp.DialWithContext(ctx))
 	if err != nil {
-		return "", fmt.Errorf("ftp dial error: %v", err)
+		return nil, fmt.Errorf("ftp dial error: %v", err)
 	}
-	defer c.Quit()
-
-	err = c.Login(username, password)
-	if err != nil {
-		return "", err
+	if err := c.Login(username, password); err != nil {
+		c.Quit()
+		return nil, err
 	}
+	return c, nil
+}
 
-	// Dynamic Path: [YYYY-MM-DD]-[ReportName]
-	now := time.Now()
-	dateFolder := fmt.Sprintf("%s-%s", now.Format("2006-01-02"), reportName)
-	fileName := fmt.Sprintf("%s-%s.%s", now.Format("2006-01-02"), now.Format("15:04"), extension)
-	finalPath := fmt.Sprintf("%s/%s", dateFolder, fileName)
-
-	// Ensure directory exists
-	_ = c.MakeDir(dateFolder)
+// removePartialFTPFile deletes what a failed upload left behind. When the
+// transfer was aborted the original connection is gone, so a fresh one is
+// opened for the cleanup.
+func removePartialFTPFile(lg *slog.Logger, c *ftp.ServerConn, aborted bool, addr, username, password, path string) {
+	if !aborted {
+		// The file may not exist at all if the server refused the upload.
+		_ = c.Delete(path)
+		return
+	}
 
-	err = c.Stor(finalPath, r)
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+	cleanup, err := dialFTP(ctx, addr, username, password)
 	if err != nil {
-		return "", err
+		lg.Warn("FTP cleanup failed", "path", path, "error", err)
+		return
+	}
+	defer cleanup.Quit()
+
+	if err := cleanup.Delete(path); err != nil {
+		lg.Warn("FTP cleanup failed", "path", path, "error", err)
 	}
-	return finalPath, nil
 }
@@ -0,0 +1,104 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sort"
+	"time"
+
+	"rbdb-backend-go/internal/logging"
+	"rbdb-backend-go/internal/models"
+)
+
+// errCancelled marks executions stopped on an operator's request.
+var errCancelled = errors.New("execution cancelled by operator")
+
+// track registers the cancel function of a running execution.
+func (p *Pool) track(executionID string, cancel context.CancelCauseFunc) {
+	p.mu.Lock()
+	p.active[executionID] = cancel
+	p.mu.Unlock()
+}
+
+func (p *Pool) untrack(executionID string) {
+	p.mu.Lock()
+	delete(p.active, executionID)
+	p.mu.Unlock()
+}
+
+// ActiveExecutions returns the IDs of the executions running on this
+// engine, sorted.
+func (p *Pool) ActiveExecutions() []string {
+	p.mu.Lock()
+	ids := make([]string, 0, len(p.active))
+	for id := range p.active {
+		ids = append(ids, id)
+	}
+	p.mu.Unlock()
+	sort.Strings(ids)
+	return ids
+}
+
+// CancelExecution stops an execution wherever it is on this engine: a
+// running job has its context cancelled and reports itself, a job parked
+// for a data source slot or waiting in the queue is removed and reported here.
+// Jobs already popped but not yet started are caught by the cancel key when
+// a worker picks them up.
+func (p *Pool) CancelExecution(ctx context.Context, executionID string) {
+	lg := slog.With(logging.KeyExecutionID, executionID)
+
+	p.mu.Lock()
+	cancel, running := p.active[executionID]
+	parked := p.limits.remove(executionID)
+	p.mu.Unlock()
+	for _, job := range parked {
+		p.ack(lg, job)
+	}
+	if running {
+		lg.Info("Cancelling running execution")
+		cancel(errCancelled)
+		return
+	}
+	if len(parked) > 0 {
+		lg.Info("Cancelling execution waiting for a data source slot")
+	}
+
+	removed, err := p.Source.RemoveQueued(ctx, executionID)
+	if err != nil {
+		lg.Error("Removing queued execution failed", "error", err)
+	}
+	if removed > 0 {
+		lg.Info("Removed queued entries of cancelled execution", "count", removed)
+	}
+	if len(parked) > 0 || removed > 0 {
+		p.reportCancelled(ctx, executionID)
+	}
+}
+
+// cancelRequested checks the cancel key before a job starts.
+func (p *Pool) cancelRequested(lg *slog.Logger, job models.Job) bool {
+	cancelled, err := p.Source.IsCancelled(context.Background(), job.ExecutionID)
+	if err != nil {
+		lg.Warn("Cancel check failed", "error", err)
+		return false
+	}
+	return cancelled
+}
+
+// reportCancelled sends the cancelled status, unless the execution is in
+// flight and already has its final status.
+func (p *Pool) reportCancelled(ctx context.Context, executionID string) {
+	if p.claimFinal(executionID) {
+		p.sendStatus(ctx, executionID, cancelledUpdate())
+	}
+}
+
+func cancelledUpdate() models.ExecutionUpdate {
+	finishTime := time.Now()
+	return models.ExecutionUpdate{
+		Status:     "cancelled",
+		FinishedAt: &finishTime,
+		ErrorLog:   errCancelled.Error(),
+	}
+}
@@ -1,4 +1,5 @@
 //go:build integration
+
 package executor
 
 import (
@@ -7,17 +8,22 @@ import (
 	"fmt"
 	"rbdb-backend-go/config"
 	"rbdb-backend-go/internal/api_client"
+	"rbdb-backend-go/internal/jobsource"
 	"rbdb-backend-go/internal/models"
+	"rbdb-backend-go/internal/queue"
 	"testing"
 
 	"github.com/redis/go-redis/v9"
 )
 
 func TestEngineDeepIntegration(t *testing.T) {
-	cfg := config.Load()
-    
-    t.Logf("Using Control Plane: %s", cfg.ControlPlaneURL)
-    t.Logf("Using Redis: %s:%s", cfg.RedisHost, cfg.RedisPort)
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatalf("Invalid configuration: %v", err)
+	}
+
+	t.Logf("Using Control Plane: %s", cfg.ControlPlaneURL)
+	t.Logf("Using Redis: %s:%s", cfg.RedisHost, cfg.RedisPort)
 
 	// Use the Execution ID from our manual setup or a previous run
 	// In a real CI, we would fetch this dynamically.
@@ -38,21 +44,21 @@ func TestEngineDeepIntegration(t *testing.T) {
 		Addr: fmt.Sprintf("%s:%s", cfg.RedisHost, cfg.RedisPort),
 	})
 	payload, _ := json.Marshal(job)
-	err := rdb.RPush(context.Background(), "rbdb_execution_queue", payload).Err()
+	err = rdb.RPush(context.Background(), cfg.QueueName, payload).Err()
 	if err != nil {
 		t.Fatalf("Failed to push to Redis: %v", err)
 	}
 
 	// 2. Process directly
 	client := api_client.NewClient(cfg)
-	pool := NewPool(cfg, client)
-	
+	pool := NewPool(cfg, client, jobsource.NewRedis(queue.New(rdb, cfg.QueueName, cfg.EngineID)))
+
 	t.Logf("Starting processing for Execution %s", executionID)
-	pool.process(job)
+	pool.process(jobLogger(job), job)
 	t.Log("Processing finished")
 
 	// 3. Status Check (via API Client)
-	report, err := client.GetReport(reportID)
+	report, err := client.GetReport(context.Background(), reportID)
 	if err != nil {
 		t.Fatalf("Failed to fetch report back: %v", err)
 	}
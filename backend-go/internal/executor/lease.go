@@ -0,0 +1,53 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+
+	"rbdb-backend-go/internal/jobsource"
+	"rbdb-backend-go/internal/models"
+)
+
+// errLeaseLost marks executions stopped because another worker may have
+// taken them over.
+var errLeaseLost = errors.New("execution lease lost")
+
+// claim takes the execution lease for job. Duplicates, whether running
+// elsewhere or already finished, are logged, acked and skipped.
+func (p *Pool) claim(lg *slog.Logger, job models.Job) (jobsource.Lease, bool) {
+	lease, err := p.Source.Claim(context.Background(), job)
+	switch {
+	case errors.Is(err, jobsource.ErrClaimed):
+		lg.Warn("Duplicate job skipped: execution is already running")
+		p.ack(lg, job)
+		return nil, false
+	case errors.Is(err, jobsource.ErrFinished):
+		lg.Warn("Duplicate job skipped: execution already finished")
+		p.ack(lg, job)
+		return nil, false
+	case err != nil:
+		lg.Error("Could not take the execution lease, returning job to the queue", "error", err)
+		p.requeue(lg, job)
+		return nil, false
+	}
+	return lease, true
+}
+
+func (p *Pool) release(lg *slog.Logger, lease jobsource.Lease) {
+	if err := lease.Release(context.Background()); err != nil {
+		lg.Warn("Lease release failed", "error", err)
+	}
+}
+
+func (p *Pool) markDone(lg *slog.Logger, job models.Job) {
+	if err := p.Source.MarkDone(context.Background(), job.ExecutionID); err != nil {
+		lg.Warn("Could not mark execution done", "error", err)
+	}
+}
+
+func (p *Pool) requeue(lg *slog.Logger, job models.Job) {
+	if _, err := p.Source.Requeue(context.Background(), job); err != nil {
+		lg.Error("Job could not be returned to the queue", "error", err)
+	}
+}
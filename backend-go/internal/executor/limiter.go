@@ -0,0 +1,261 @@
+package executor
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"time"
+
+	"rbdb-backend-go/internal/logging"
+	"rbdb-backend-go/internal/models"
+	"rbdb-backend-go/internal/tracing"
+)
+
+// overflowDelay is how long jobs wait in the source when too many are already
+// parked locally, leaving them to other engines in the meantime.
+const overflowDelay = 10 * time.Second
+
+// slot is the share of a data source a running job holds.
+type slot struct {
+	source string
+	kind   string
+}
+
+type parkedJob struct {
+	job  models.Job
+	slot slot
+}
+
+// sourceLimiter caps concurrent executions per data source and per data
+// source type. Jobs over a cap are parked in arrival order until a slot
+// frees up. It is not safe for concurrent use; the pool guards it with mu.
+type sourceLimiter struct {
+	perSource map[string]int
+	perType   map[string]int
+	bySource  map[string]int
+	byType    map[string]int
+	parked    []parkedJob
+	maxParked int
+	// reports remembers the slot of each report seen, so jobs don't fetch
+	// their report once for the slot and again to run.
+	reports map[string]slot
+}
+
+func newSourceLimiter(perSource, perType map[string]int, maxParked int) *sourceLimiter {
+	return &sourceLimiter{
+		perSource: perSource,
+		perType:   perType,
+		bySource:  make(map[string]int),
+		byType:    make(map[string]int),
+		maxParked: maxParked,
+		reports:   make(map[string]slot),
+	}
+}
+
+func (l *sourceLimiter) enabled() bool {
+	return len(l.perSource) > 0 || len(l.perType) > 0
+}
+
+func (l *sourceLimiter) fits(s slot) bool {
+	if limit, ok := l.perSource[s.source]; ok && l.bySource[s.source] >= limit {
+		return false
+	}
+	if limit, ok := l.perType[s.kind]; ok && l.byType[s.kind] >= limit {
+		return false
+	}
+	return true
+}
+
+// tryAcquire takes a slot if neither cap is reached.
+func (l *sourceLimiter) tryAcquire(s slot) bool {
+	if !l.fits(s) {
+		return false
+	}
+	l.take(s)
+	return true
+}
+
+func (l *sourceLimiter) take(s slot) {
+	l.bySource[s.source]++
+	l.byType[s.kind]++
+}
+
+func (l *sourceLimiter) release(s slot) {
+	if l.bySource[s.source]--; l.bySource[s.source] <= 0 {
+		delete(l.bySource, s.source)
+	}
+	if l.byType[s.kind]--; l.byType[s.kind] <= 0 {
+		delete(l.byType, s.kind)
+	}
+}
+
+// park queues a job for later, returning false when too many are parked.
+func (l *sourceLimiter) park(job models.Job, s slot) bool {
+	if len(l.parked) >= l.maxParked {
+		return false
+	}
+	l.parked = append(l.parked, parkedJob{job, s})
+	return true
+}
+
+// unpark takes the oldest parked job that fits and acquires its slot.
+func (l *sourceLimiter) unpark() (parkedJob, bool) {
+	for i, pj := range l.parked {
+		if l.fits(pj.slot) {
+			l.parked = append(l.parked[:i], l.parked[i+1:]...)
+			l.take(pj.slot)
+			return pj, true
+		}
+	}
+	return parkedJob{}, false
+}
+
+// remove drops the parked jobs of an execution and returns them.
+func (l *sourceLimiter) remove(executionID string) []models.Job {
+	var removed []models.Job
+	kept := l.parked[:0]
+	for _, pj := range l.parked {
+		if pj.job.ExecutionID == executionID {
+			removed = append(removed, pj.job)
+		} else {
+			kept = append(kept, pj)
+		}
+	}
+	l.parked = kept
+	return removed
+}
+
+// drain empties the parked list.
+func (l *sourceLimiter) drain() []models.Job {
+	jobs := make([]models.Job, 0, len(l.parked))
+	for _, pj := range l.parked {
+		jobs = append(jobs, pj.job)
+	}
+	l.parked = nil
+	return jobs
+}
+
+// slotOf resolves the data source a job will query, from the last run of
+// its report if there was one. Jobs whose report can't be fetched get an
+// empty slot and run unthrottled; execute reports the failure through the
+// usual retry path.
+func (p *Pool) slotOf(job models.Job) slot {
+	p.mu.Lock()
+	s, ok := p.limits.reports[job.ReportID]
+	p.mu.Unlock()
+	if ok {
+		return s
+	}
+
+	report, err := p.ApiClient.GetReport(tracing.Extract(context.Background(), job.TraceContext), job.ReportID)
+	if err != nil || report == nil {
+		return slot{}
+	}
+	return p.rememberSlot(job.ReportID, report)
+}
+
+// rememberSlot records the data source of a freshly fetched report for
+// slotOf and returns its slot.
+func (p *Pool) rememberSlot(reportID string, report *models.Report) slot {
+	source := report.DataSourceID
+	if source == "" {
+		source = report.DataSource.ID
+	}
+	s := slot{source: source, kind: strings.ToLower(report.DataSource.Type)}
+	p.mu.Lock()
+	p.limits.reports[reportID] = s
+	p.mu.Unlock()
+	return s
+}
+
+// dispatch runs a job once its data source has room and parks it otherwise,
+// so the worker can move on to jobs for other sources. A worker finishing a
+// job hands its slot straight to the next parked job that fits.
+func (p *Pool) dispatch(worker int, job models.Job) {
+	lg := jobLogger(job).With(logging.KeyWorker, worker)
+	p.mu.Lock()
+	limited := p.limits.enabled()
+	p.mu.Unlock()
+	if !limited {
+		p.run(lg, job)
+		return
+	}
+
+	s := p.slotOf(job)
+	p.mu.Lock()
+	if !p.limits.tryAcquire(s) {
+		p.running--
+		draining := p.draining
+		parked := !draining && p.limits.park(job, s)
+		p.mu.Unlock()
+		switch {
+		case parked:
+			lg.Info("Waiting for a free data source slot", logging.KeyDataSourceID, s.source)
+		case draining:
+			p.requeue(lg, job)
+		default:
+			p.postpone(lg, job)
+		}
+		return
+	}
+	p.mu.Unlock()
+
+	for {
+		p.runHolding(lg, job, s)
+
+		p.mu.Lock()
+		if p.draining {
+			// Parked jobs are handed back to the source by Shutdown.
+			p.mu.Unlock()
+			return
+		}
+		next, ok := p.limits.unpark()
+		if !ok {
+			p.mu.Unlock()
+			return
+		}
+		p.running++
+		p.mu.Unlock()
+
+		job, s = next.job, next.slot
+		lg = jobLogger(job).With(logging.KeyWorker, worker)
+		lg.Info("Got a data source slot", logging.KeyDataSourceID, s.source)
+	}
+}
+
+// runHolding runs a job on slot s and gives the slot back even if the job
+// panics. A panic unwinds past the hand-off to parked jobs, so the next one
+// that fits goes back to the source rather than wait for a slot nobody
+// frees.
+func (p *Pool) runHolding(lg *slog.Logger, job models.Job, s slot) {
+	finished := false
+	defer func() {
+		p.mu.Lock()
+		p.limits.release(s)
+		var next parkedJob
+		handOff := false
+		if !finished && !p.draining {
+			if next, handOff = p.limits.unpark(); handOff {
+				p.limits.release(next.slot)
+			}
+		}
+		p.mu.Unlock()
+		if handOff {
+			p.requeue(jobLogger(next.job), next.job)
+		}
+	}()
+	p.run(lg, job)
+	finished = true
+}
+
+// postpone hands a job that can't be parked back to the source for later.
+func (p *Pool) postpone(lg *slog.Logger, job models.Job) {
+	at := time.Now().Add(overflowDelay)
+	if err := p.Source.Defer(context.Background(), job, at); err != nil {
+		lg.Error("Job could not be postponed, returning it to the queue", "error", err)
+		p.requeue(lg, job)
+		return
+	}
+	lg.Info("Job postponed: data source at capacity")
+	p.ack(lg, job)
+}
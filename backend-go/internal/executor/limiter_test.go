@@ -0,0 +1,92 @@
+package executor
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+
+	"rbdb-backend-go/config"
+	"rbdb-backend-go/internal/jobsource"
+	"rbdb-backend-go/internal/models"
+	"rbdb-backend-go/internal/queue"
+)
+
+func TestSourceLimiterCaps(t *testing.T) {
+	l := newSourceLimiter(map[string]int{"replica": 1}, map[string]int{"oracle": 2}, 10)
+	replica := slot{source: "replica", kind: "oracle"}
+	warehouse := slot{source: "warehouse", kind: "oracle"}
+	mysql := slot{source: "shop", kind: "mysql"}
+
+	if !l.tryAcquire(replica) {
+		t.Fatal("first replica job should run")
+	}
+	if l.tryAcquire(replica) {
+		t.Fatal("second replica job should exceed the source cap")
+	}
+	if !l.tryAcquire(warehouse) {
+		t.Fatal("warehouse job should fit under the oracle cap")
+	}
+	if l.tryAcquire(slot{source: "archive", kind: "oracle"}) {
+		t.Fatal("third oracle job should exceed the type cap")
+	}
+	if !l.tryAcquire(mysql) {
+		t.Fatal("uncapped sources should not be limited")
+	}
+
+	l.park(models.Job{ExecutionID: "waiting"}, replica)
+	if _, ok := l.unpark(); ok {
+		t.Fatal("parked job should wait while the replica is busy")
+	}
+	l.release(replica)
+	next, ok := l.unpark()
+	if !ok || next.job.ExecutionID != "waiting" {
+		t.Fatalf("expected parked job to get the freed slot, got %+v", next)
+	}
+	if l.tryAcquire(replica) {
+		t.Fatal("unparked job should hold the replica slot")
+	}
+}
+
+func TestSourceLimiterParkBound(t *testing.T) {
+	l := newSourceLimiter(map[string]int{"replica": 1}, nil, 1)
+	s := slot{source: "replica"}
+	if !l.park(models.Job{ExecutionID: "a"}, s) {
+		t.Fatal("first job should be parked")
+	}
+	if l.park(models.Job{ExecutionID: "b"}, s) {
+		t.Fatal("park should refuse jobs beyond the bound")
+	}
+	if removed := l.remove("a"); len(removed) != 1 || len(l.drain()) != 0 {
+		t.Fatalf("expected a removed and nothing left, got %v", removed)
+	}
+}
+
+func TestPanickingJobGivesBackItsSlot(t *testing.T) {
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	cfg := &config.Config{WorkerCount: 1, JobTimeout: time.Minute, DataSourceLimits: map[string]int{"replica": 1}}
+	// Without a control plane client the first status update panics.
+	p := NewPool(cfg, nil, jobsource.NewRedis(queue.New(rdb, "test", "engine-a")))
+	replica := slot{source: "replica", kind: "oracle"}
+	p.limits.reports["r1"] = replica
+	p.limits.park(models.Job{ExecutionID: "waiting", ReportID: "r1"}, replica)
+
+	p.running = 1
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Fatal("expected the job to panic")
+			}
+		}()
+		p.dispatch(0, models.Job{ExecutionID: "exec-1", ReportID: "r1"})
+	}()
+
+	if !p.limits.tryAcquire(replica) {
+		t.Fatal("the panicked job kept its data source slot")
+	}
+	if len(p.limits.parked) != 0 {
+		t.Fatal("the parked job should have been handed back to the source")
+	}
+}
@@ -2,221 +2,769 @@ package executor
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
-	"log"
-	"rbdb-backend-go/config"
+	"log/slog"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 
+	"rbdb-backend-go/config"
 	"rbdb-backend-go/internal/api_client"
 	"rbdb-backend-go/internal/delivery"
+	"rbdb-backend-go/internal/jobsource"
+	"rbdb-backend-go/internal/logging"
+	"rbdb-backend-go/internal/metrics"
 	"rbdb-backend-go/internal/models"
+	"rbdb-backend-go/internal/outbox"
 	"rbdb-backend-go/internal/output"
+	"rbdb-backend-go/internal/queue"
 	"rbdb-backend-go/internal/report_builder"
 	"rbdb-backend-go/internal/security"
-	"time"
+	"rbdb-backend-go/internal/tracing"
 )
 
+// errInterrupted marks executions cut short by an engine shutdown.
+var errInterrupted = errors.New("execution interrupted by engine shutdown")
+
 type Pool struct {
-	JobQueue    chan models.Job
-	WorkerCount int
-	ApiClient   *api_client.Client
-	Config      *config.Config
+	ApiClient *api_client.Client
+	Source    jobsource.JobSource
+	Config    *config.Config
+	// Outbox carries status updates to the control plane. Without one they
+	// are sent directly and lost if that fails.
+	Outbox *outbox.Outbox
+
+	// One buffered lane per priority; ready holds one token per buffered job.
+	lanes     map[string]chan models.Job
+	ready     chan struct{}
+	scheduler *queue.Scheduler
+	limits    *sourceLimiter
+
+	// ctx is the parent of every execution context; cancelling it interrupts
+	// all running jobs at the end of the shutdown grace period.
+	ctx       context.Context
+	interrupt context.CancelFunc
+	quit      chan struct{}
+
+	mu       sync.Mutex
+	draining bool
+	running  int
+	active   map[string]context.CancelCauseFunc
+	inflight map[string]*inflight
+
+	// workers is the configured worker count and started the IDs of the
+	// live workers. resized is closed and replaced by Resize to wake idle
+	// workers, so those beyond the new count can exit.
+	workers  int
+	started  map[int]bool
+	resized  chan struct{}
+	defaults jobDefaults
+
+	// dbPools holds the connection pools of the data sources queried.
+	dbPools *report_builder.Pools
+}
+
+// jobDefaults are the reloadable settings applied to jobs that don't carry
+// their own.
+type jobDefaults struct {
+	timeout    time.Duration
+	staleGrace time.Duration
+	format     output.Format
+	retention  time.Duration
+}
+
+func defaultsOf(cfg *config.Config) jobDefaults {
+	return jobDefaults{
+		timeout:    cfg.JobTimeout,
+		staleGrace: cfg.StaleGrace,
+		format:     output.Format(cfg.OutputFormat),
+		retention:  cfg.OutputRetention,
+	}
 }
 
-func NewPool(cfg *config.Config, client *api_client.Client) *Pool {
+func NewPool(cfg *config.Config, client *api_client.Client, source jobsource.JobSource) *Pool {
+	// Keep the local buffer small: prioritization happens in the source, and
+	// jobs parked here can't be overtaken by more urgent ones still queued.
+	// The buffers keep their size when the worker count is reloaded.
+	laneSize := cfg.WorkerCount
+	if laneSize < 1 {
+		laneSize = 1
+	}
+	lanes := make(map[string]chan models.Job, len(queue.Priorities))
+	for _, lane := range queue.Priorities {
+		lanes[lane] = make(chan models.Job, laneSize)
+	}
+
+	ctx, interrupt := context.WithCancel(context.Background())
 	return &Pool{
-		JobQueue:    make(chan models.Job, 100),
-		WorkerCount: cfg.WorkerCount,
-		ApiClient:   client,
-		Config:      cfg,
+		ApiClient: client,
+		Source:    source,
+		Config:    cfg,
+		lanes:     lanes,
+		ready:     make(chan struct{}, laneSize*len(queue.Priorities)),
+		scheduler: queue.NewScheduler(queue.DefaultWeights),
+		limits:    newSourceLimiter(cfg.DataSourceLimits, cfg.DataSourceTypeLimits, laneSize*len(queue.Priorities)),
+		ctx:       ctx,
+		interrupt: interrupt,
+		quit:      make(chan struct{}),
+		active:    make(map[string]context.CancelCauseFunc),
+		inflight:  make(map[string]*inflight),
+		workers:   cfg.WorkerCount,
+		started:   make(map[int]bool),
+		resized:   make(chan struct{}),
+		defaults:  defaultsOf(cfg),
+		dbPools:   report_builder.NewPools(poolSettingsOf(cfg)),
+	}
+}
+
+func poolSettingsOf(cfg *config.Config) report_builder.PoolSettings {
+	return report_builder.PoolSettings{
+		MaxOpen:     cfg.DBMaxOpenConns,
+		MaxIdle:     cfg.DBMaxIdleConns,
+		MaxLifetime: cfg.DBConnMaxLifetime,
+		MaxIdleTime: cfg.DBConnMaxIdleTime,
 	}
 }
 
 func (p *Pool) Start() {
-	for i := 0; i < p.WorkerCount; i++ {
-		go p.worker(i)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.startWorkers()
+	go p.reap()
+}
+
+// startWorkers starts the missing workers up to the configured count.
+// Callers hold p.mu.
+func (p *Pool) startWorkers() {
+	for id := 0; id < p.workers; id++ {
+		if !p.started[id] {
+			p.started[id] = true
+			go p.worker(id)
+		}
+	}
+}
+
+// Resize changes the number of workers. Extra workers finish their current
+// job before they exit.
+func (p *Pool) Resize(n int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.draining || n == p.workers {
+		return
+	}
+	p.workers = n
+	p.startWorkers()
+	close(p.resized)
+	p.resized = make(chan struct{})
+}
+
+// Workers returns the configured number of workers.
+func (p *Pool) Workers() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.workers
+}
+
+// Reload applies the settings of cfg that can change at runtime: the worker
+// count, data source limits and job defaults. Parked jobs are handed back to
+// the source when the limits change, so they are checked against the new caps.
+func (p *Pool) Reload(cfg *config.Config) {
+	p.Resize(cfg.WorkerCount)
+
+	p.mu.Lock()
+	var parked []models.Job
+	if p.Config.LimitsChanged(cfg) {
+		p.limits.perSource = cfg.DataSourceLimits
+		p.limits.perType = cfg.DataSourceTypeLimits
+		parked = p.limits.drain()
+	}
+	p.defaults = defaultsOf(cfg)
+	p.Config = cfg
+	p.mu.Unlock()
+	p.dbPools.Configure(poolSettingsOf(cfg))
+
+	for _, job := range parked {
+		p.requeue(jobLogger(job), job)
+	}
+}
+
+func (p *Pool) jobDefaults() jobDefaults {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.defaults
+}
+
+// AddJob buffers a job in its priority lane, blocking while that lane is
+// full. It gives up when ctx is done, leaving the job with the caller.
+func (p *Pool) AddJob(ctx context.Context, job models.Job) error {
+	lane := job.Receipt.Lane
+	if lane == "" {
+		lane = queue.Lane(job.Priority)
+	}
+	select {
+	case p.lanes[lane] <- job:
+		p.ready <- struct{}{}
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// next blocks until a job is buffered and takes one using weighted fair
+// dequeuing across the lanes. It returns false once the pool is draining or
+// worker id is beyond the configured count.
+func (p *Pool) next(id int) (models.Job, bool) {
+	var job models.Job
+	if !p.await(id) {
+		return job, false
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.draining {
+		// Leave the job buffered for Shutdown to hand back to the source.
+		p.ready <- struct{}{}
+		return job, false
+	}
+
+	p.scheduler.Pick(func(lane string) bool {
+		select {
+		case job = <-p.lanes[lane]:
+			return true
+		default:
+			return false
+		}
+	})
+	p.running++
+	return job, true
+}
+
+// await blocks until a job is buffered. It returns false when the pool quits
+// or worker id is retired by Resize.
+func (p *Pool) await(id int) bool {
+	for {
+		p.mu.Lock()
+		if id >= p.workers {
+			delete(p.started, id)
+			p.mu.Unlock()
+			return false
+		}
+		resized := p.resized
+		p.mu.Unlock()
+
+		select {
+		case <-p.quit:
+			return false
+		case <-resized:
+		case <-p.ready:
+			return true
+		}
+	}
+}
+
+func (p *Pool) done() {
+	p.mu.Lock()
+	p.running--
+	p.mu.Unlock()
+}
+
+// Running returns the number of executions in progress.
+func (p *Pool) Running() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.running
+}
+
+// Shutdown stops workers from taking new jobs, hands buffered jobs back to
+// the source and waits up to grace for running executions. Executions still
+// running after that are interrupted and requeued. Callers must stop adding
+// jobs before calling Shutdown.
+func (p *Pool) Shutdown(grace time.Duration) {
+	p.mu.Lock()
+	p.draining = true
+	p.mu.Unlock()
+	close(p.quit)
+	defer p.dbPools.Close()
+
+	p.returnBuffered()
+
+	if p.waitIdle(grace) {
+		slog.Info("All running executions finished")
+		return
+	}
+
+	slog.Warn("Grace period expired, interrupting running executions", "grace", grace.String(), "running", p.Running())
+	p.interrupt()
+	// Interrupted executions only need to report and requeue themselves.
+	if !p.waitIdle(10 * time.Second) {
+		slog.Error("Executions did not report back after interruption", "running", p.Running())
 	}
 }
 
-func (p *Pool) AddJob(job models.Job) {
-	p.JobQueue <- job
+// returnBuffered hands every job still waiting in the local lanes or parked
+// for a data source slot back to the source.
+func (p *Pool) returnBuffered() {
+	p.mu.Lock()
+	parked := p.limits.drain()
+	p.mu.Unlock()
+	for _, job := range parked {
+		p.requeue(jobLogger(job), job)
+	}
+
+	for _, ch := range p.lanes {
+		for drained := false; !drained; {
+			select {
+			case job := <-ch:
+				lg := jobLogger(job)
+				if _, err := p.Source.Requeue(context.Background(), job); err != nil {
+					lg.Error("Job could not be returned to the queue", "error", err)
+				} else {
+					lg.Info("Job returned to the queue")
+				}
+			default:
+				drained = true
+			}
+		}
+	}
 }
 
+// reportInterrupted requeues an execution cut short by shutdown and tells the
+// control plane what happened to it.
+func (p *Pool) reportInterrupted(ctx context.Context, lg *slog.Logger, job models.Job) {
+	status := "requeued"
+	requeued, err := p.Source.Requeue(context.Background(), job)
+	if err != nil || !requeued {
+		// Still held by this engine; the source hands it out again once
+		// the engine is gone.
+		status = "interrupted"
+		lg.Error("Job interrupted and could not be requeued", "error", err)
+	} else {
+		lg.Warn("Job interrupted and requeued")
+	}
 
+	p.sendStatus(ctx, job.ExecutionID, models.ExecutionUpdate{
+		Status:   status,
+		ErrorLog: errInterrupted.Error(),
+		Attempt:  attemptOf(job),
+	})
+}
+
+func (p *Pool) waitIdle(timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for p.Running() > 0 {
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	return true
+}
 
+// counter adds the bytes read to Total, which may be read concurrently.
 type counter struct {
 	io.Reader
-	Total int64
+	Total *atomic.Int64
 }
 
 func (c *counter) Read(p []byte) (int, error) {
 	n, err := c.Reader.Read(p)
-	c.Total += int64(n)
+	c.Total.Add(int64(n))
 	return n, err
 }
 
-func (p *Pool) process(job models.Job) {
+func (p *Pool) process(lg *slog.Logger, job models.Job) {
+	// The execution span continues the producer's trace when the payload
+	// carries one. API calls use traceCtx, which is never cancelled.
+	traceCtx, span := tracing.Start(tracing.Extract(context.Background(), job.TraceContext), "execution",
+		attribute.String("execution.id", job.ExecutionID),
+		attribute.String("report.id", job.ReportID),
+		attribute.String("job.id", job.JobID),
+		attribute.String("execution.priority", queue.Lane(job.Priority)),
+		attribute.Int("execution.attempt", attemptOf(job)),
+	)
+	defer span.End()
+	if sc := span.SpanContext(); sc.HasTraceID() {
+		lg = lg.With("trace_id", sc.TraceID().String())
+	}
+
+	if p.cancelRequested(lg, job) {
+		lg.Info("Job was cancelled before it started")
+		span.SetAttributes(attribute.String("execution.status", "cancelled"))
+		p.reportCancelled(traceCtx, job.ExecutionID)
+		p.ack(lg, job)
+		return
+	}
+
+	// Only one worker across all engines may run an execution at a time.
+	lease, ok := p.claim(lg, job)
+	if !ok {
+		return
+	}
+	defer p.release(lg, lease)
+
 	startTime := time.Now()
 
+	// Create a context with timeout from Job
+	defaults := p.jobDefaults()
+	timeout := defaults.timeout
+	if job.TimeoutSeconds > 0 {
+		timeout = time.Duration(job.TimeoutSeconds) * time.Second
+	}
+
 	// 1. Update Status: Processing
-	p.ApiClient.UpdateExecution(job.ExecutionID, models.ExecutionUpdate{
+	p.begin(job, startTime, timeout)
+	p.sendStatus(traceCtx, job.ExecutionID, models.ExecutionUpdate{
 		Status:    "processing",
 		StartedAt: &startTime,
+		Attempt:   attemptOf(job),
 	})
 
 	var (
-		outputPath string
-		fileSize   int64
-		otp        string
-		expiresAt  *time.Time
-		err        error
+		result outcome
+		err    error
 	)
-
-	// Create a context with timeout from Job
-	timeout := 5 * time.Minute
-	if job.TimeoutSeconds > 0 {
-		timeout = time.Duration(job.TimeoutSeconds) * time.Second
-	}
-	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	prog := newProgress(startTime)
+	ctx, cancel := context.WithTimeout(trace.ContextWithSpan(p.ctx, span), timeout)
 	defer cancel()
-
-	// Track if process finished
-	done := make(chan bool, 1)
+	ctx, cancelJob := context.WithCancelCause(ctx)
+	defer cancelJob(nil)
+	p.track(job.ExecutionID, cancelJob)
+	defer p.untrack(job.ExecutionID)
+
+	renewCtx, stopRenewing := context.WithCancel(ctx)
+	go lease.KeepAlive(renewCtx, func(cause error) {
+		if errors.Is(cause, jobsource.ErrCancelled) {
+			cancelJob(errCancelled)
+		} else {
+			cancelJob(errLeaseLost)
+		}
+	})
+	defer stopRenewing()
 
 	defer func() {
+		if result.dataSourceID != "" {
+			lg = lg.With(logging.KeyDataSourceID, result.dataSourceID)
+		}
+		tracing.RecordError(span, err)
+		if errors.Is(context.Cause(ctx), errReaped) {
+			// The reaper already settled it.
+			return
+		}
+		claimed, reaped := p.claimOwn(job.ExecutionID)
+		if reaped {
+			// The reaper took it over before it could cancel the worker;
+			// it acks the job and decides on its retry.
+			return
+		}
+		if !claimed {
+			// Cancelled as it started, and already reported as such.
+			p.settle(job.ExecutionID)
+			p.markDone(lg, job)
+			p.ack(lg, job)
+			return
+		}
+		if errors.Is(err, errInterrupted) {
+			p.reportInterrupted(traceCtx, lg, job)
+			p.settle(job.ExecutionID)
+			return
+		}
+		if errors.Is(err, errLeaseLost) {
+			// Someone else may own it now; a requeued copy is skipped if so.
+			lg.Warn("Job lost its lease, returning it to the queue")
+			p.requeue(lg, job)
+			p.settle(job.ExecutionID)
+			return
+		}
+		if errors.Is(err, errCancelled) {
+			lg.Info("Job cancelled")
+			p.finish(traceCtx, job.ExecutionID, cancelledUpdate())
+			p.markDone(lg, job)
+			p.ack(lg, job)
+			return
+		}
+
+		final := prog.snapshot()
 		finishTime := time.Now()
+		finishedAt := &finishTime
 		status := "completed"
 		errorLog := ""
+		var nextRetryAt *time.Time
 		if err != nil {
+			metrics.JobsFailed.WithLabelValues(result.reportType, result.sourceType).Inc()
 			status = "failed"
 			errorLog = err.Error()
-			log.Printf("Job %s failed: %v", job.ExecutionID, err)
+			lg.Error("Job failed", "attempt", attemptOf(job), "error", err)
+
+			job.AttemptHistory = append(job.AttemptHistory, models.AttemptRecord{
+				Attempt:    attemptOf(job),
+				Error:      errorLog,
+				StartedAt:  startTime,
+				FinishedAt: finishTime,
+			})
+
+			status, nextRetryAt = p.retryOrGiveUp(lg, job, err)
+			if nextRetryAt != nil {
+				finishedAt = nil
+			}
 		} else {
-			log.Printf("Job %s completed", job.ExecutionID)
+			metrics.JobsCompleted.WithLabelValues(result.reportType, result.sourceType).Inc()
+			metrics.DeliveredBytes.Add(float64(result.fileSize))
+			lg.Info("Job completed", "rows", final.Rows, "bytes", result.fileSize, "duration_seconds", finishTime.Sub(startTime).Seconds())
 		}
 
-		p.ApiClient.UpdateExecution(job.ExecutionID, models.ExecutionUpdate{
-			Status:     status,
-			FinishedAt: &finishTime,
-			ErrorLog:   errorLog,
-			OutputPath: outputPath,
-			FileSize:   fileSize,
-			OTP:        otp,
-			ExpiresAt:  expiresAt,
+		span.SetAttributes(
+			attribute.String("execution.status", status),
+			attribute.Int64("execution.rows", final.Rows),
+			attribute.Int64("execution.bytes", result.fileSize),
+		)
+		p.finish(traceCtx, job.ExecutionID, models.ExecutionUpdate{
+			Status:      status,
+			FinishedAt:  finishedAt,
+			ErrorLog:    errorLog,
+			OutputPath:  result.outputPath,
+			FileSize:    result.fileSize,
+			OTP:         result.otp,
+			ExpiresAt:   result.expiresAt,
+			Attempt:     attemptOf(job),
+			NextRetryAt: nextRetryAt,
+			Progress:    &final,
 		})
+
+		if status != "retrying" {
+			p.markDone(lg, job)
+		}
+		// The job only leaves the source once its final status is out.
+		p.ack(lg, job)
 	}()
 
-	// Run processing in a goroutine to allow timeout control
-	go func() {
-		defer func() { done <- true }()
+	// Stopped before the deferred final update above.
+	stopProgress := p.reportProgress(traceCtx, lg, job, prog)
+	defer stopProgress()
 
-		// 2. Fetch Report (Optional if SQL is provided in Go, but still needed for Delivery Config)
-		report, subErr := p.ApiClient.GetReport(job.ReportID)
-		if subErr != nil {
-			err = subErr
-			return
+	// A panic while executing fails the attempt like any other error.
+	defer func() {
+		if r := recover(); r != nil {
+			lg.Error("Execution panicked", "panic", fmt.Sprint(r), "stack", string(debug.Stack()))
+			err = fmt.Errorf("execution panicked: %v", r)
 		}
+	}()
 
-		// 3. Build & Execute
-		builder := report_builder.NewBuilder()
-		rows, db, subErr := builder.ExecuteAndReturnRows(ctx, report, job)
+	// Runs synchronously: every stage watches ctx, so when this returns the
+	// query, generator and upload have all stopped and the slot can be reused.
+	result, err = p.execute(logging.NewContext(ctx, lg), job, prog)
+	if err != nil && ctx.Err() != nil {
+		if p.ctx.Err() != nil {
+			err = errInterrupted
+		} else if cause := context.Cause(ctx); errors.Is(cause, errCancelled) || errors.Is(cause, errLeaseLost) {
+			err = cause
+		} else if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			err = fmt.Errorf("execution timed out after %s: %w", timeout, err)
+		}
+	}
+}
 
-		if subErr != nil {
-			err = subErr
+// sendStatus hands a status update to the outbox, which delivers it in
+// order with the execution's other updates. If the outbox can't take it,
+// it is sent directly.
+func (p *Pool) sendStatus(ctx context.Context, executionID string, update models.ExecutionUpdate) {
+	lg := slog.With(logging.KeyExecutionID, executionID)
+	if p.Outbox != nil {
+		err := p.Outbox.Enqueue(executionID, update)
+		if err == nil {
 			return
 		}
-		defer db.Close()
-		defer rows.Close()
+		lg.Error("Outbox unavailable, sending status update directly", "status", update.Status, "error", err)
+	}
+	if err := p.ApiClient.UpdateExecution(ctx, executionID, update); err != nil {
+		lg.Error("Status update lost", "status", update.Status, "error", err)
+	}
+}
 
-		// 4. Delivery Setup
-		format := output.FormatCSV
-		if report.Type == "sql" || report.Type == "visual" {
-			format = output.FormatXLSX
-		}
+func (p *Pool) ack(lg *slog.Logger, job models.Job) {
+	if err := p.Source.Ack(context.Background(), job); err != nil {
+		lg.Error("Ack failed", "error", err)
+	}
+}
 
-		deliveryConfig := map[string]interface{}{
-			"report_name": report.Name,
-			"extension":   string(format),
-		}
-		if report.FtpServer.ID != "" {
-			for k, v := range report.FtpServer.ConnectionConfig {
-				deliveryConfig[k] = v
-			}
-		}
+// jobLogger returns a logger carrying the job's correlation IDs.
+func jobLogger(job models.Job) *slog.Logger {
+	return slog.With(
+		logging.KeyExecutionID, job.ExecutionID,
+		logging.KeyReportID, job.ReportID,
+		logging.KeyJobID, job.JobID,
+	)
+}
 
-		// 5. Generate OTP
-		otp, _ = security.GenerateOTP()
+// outcome holds what a successful execution reports back, plus the metric
+// labels of the report, which are set even when it fails.
+type outcome struct {
+	outputPath string
+	fileSize   int64
+	otp        string
+	expiresAt  *time.Time
+
+	reportType   string
+	sourceType   string
+	dataSourceID string
+}
 
-		// 6. Streaming Delivery
-		pr, pw := io.Pipe()
-		countReader := &counter{Reader: pr}
-		
-		errChan := make(chan error, 1)
-		pathChan := make(chan string, 1)
+func (p *Pool) execute(ctx context.Context, job models.Job, prog *progress) (outcome, error) {
+	result := outcome{reportType: metrics.Unknown, sourceType: metrics.Unknown}
 
-		// One goroutine for FTP Upload
-		go func() {
-			defer pr.Close()
-			finalPath, uploadErr := delivery.SendStream(delivery.TypeFTP, deliveryConfig, countReader)
-			pathChan <- finalPath
-			errChan <- uploadErr
-		}()
+	// 2. Fetch Report (Optional if SQL is provided in Go, but still needed for Delivery Config)
+	prog.setStage(models.StageFetchingReport)
+	report, err := p.ApiClient.GetReport(ctx, job.ReportID)
+	if err != nil {
+		metrics.JobsStarted.WithLabelValues(result.reportType, result.sourceType).Inc()
+		return result, err
+	}
+	result.dataSourceID = p.rememberSlot(job.ReportID, report).source
+	ctx = logging.NewContext(ctx, logging.FromContext(ctx).With(logging.KeyDataSourceID, result.dataSourceID))
+	result.reportType = metrics.Label(report.Type)
+	result.sourceType = metrics.Label(strings.ToLower(report.DataSource.Type))
+	metrics.JobsStarted.WithLabelValues(result.reportType, result.sourceType).Inc()
+
+	// 3. Build & Execute
+	builder := report_builder.NewBuilder(p.dbPools)
+	builder.OnStage = prog.setStage
+	sourceAttrs := []attribute.KeyValue{
+		attribute.String("report.type", report.Type),
+		attribute.String("data_source.id", result.dataSourceID),
+		attribute.String("data_source.type", report.DataSource.Type),
+	}
+	trace.SpanFromContext(ctx).SetAttributes(sourceAttrs...)
+	queryCtx, querySpan := tracing.Start(ctx, "query", sourceAttrs...)
+	queryStart := time.Now()
+	rows, err := builder.ExecuteAndReturnRows(queryCtx, report, job)
+	metrics.ObserveStage(metrics.StageQuery, queryStart)
+	tracing.End(querySpan, err)
+	if err != nil {
+		return result, err
+	}
+	defer rows.Close()
 
+	// 4. Delivery Setup
+	defaults := p.jobDefaults()
+	format := defaults.format
+	if report.Type == "sql" || report.Type == "visual" {
+		format = output.FormatXLSX
+	}
 
-		// Main goroutine for generation
-		subErr = output.WriteTo(rows, format, pw, report)
-		pw.Close()
+	deliveryConfig := map[string]interface{}{
+		"report_name": report.Name,
+		"extension":   string(format),
+	}
+	if report.FtpServer.ID != "" {
+		for k, v := range report.FtpServer.ConnectionConfig {
+			deliveryConfig[k] = v
+		}
+	}
 
+	// 5. Generate OTP
+	result.otp, _ = security.GenerateOTP()
 
-		uploadErr := <-errChan
-		outputPath = <-pathChan
-		fileSize = countReader.Total
+	// 6. Streaming Delivery
+	pr, pw := io.Pipe()
+	countReader := &counter{Reader: pr, Total: &prog.bytes}
 
-		if subErr != nil {
-			err = subErr
-			return
-		}
+	type uploadResult struct {
+		path string
+		err  error
+	}
+	uploaded := make(chan uploadResult, 1)
+
+	// One goroutine for FTP Upload
+	go func() {
+		defer metrics.ObserveStage(metrics.StageUpload, time.Now())
+		uploadCtx, uploadSpan := tracing.Start(ctx, "upload", attribute.String("delivery.type", string(delivery.TypeFTP)))
+		finalPath, uploadErr := delivery.SendStream(uploadCtx, delivery.TypeFTP, deliveryConfig, countReader)
+		uploadSpan.SetAttributes(
+			attribute.Int64("upload.bytes", countReader.Total.Load()),
+			attribute.String("upload.path", finalPath),
+		)
+		tracing.End(uploadSpan, uploadErr)
+		// Unblock the generator if the upload stopped early.
 		if uploadErr != nil {
-			err = uploadErr
-			return
+			pr.CloseWithError(uploadErr)
+		} else {
+			pr.Close()
 		}
-
-		// 7. Success State & Metadata
-		finishTime := time.Now()
-		
-		// Calculate Expiry
-		duration, _ := time.ParseDuration(report.RetentionPeriod) // e.g. "24h"
-		if duration == 0 {
-			duration = 24 * time.Hour
-		}
-		exp := finishTime.Add(duration)
-		expiresAt = &exp
+		uploaded <- uploadResult{finalPath, uploadErr}
 	}()
 
-	select {
-	case <-done:
-		// Normal completion
-	case <-ctx.Done():
-		err = fmt.Errorf("execution timed out after %d seconds", job.TimeoutSeconds)
+	// Main goroutine for generation; a failure aborts the upload through the pipe
+	prog.setStage(models.StageGenerating)
+	genCtx, genSpan := tracing.Start(ctx, "generate", attribute.String("output.format", string(format)))
+	genStart := time.Now()
+	genErr := output.WriteTo(genCtx, rows, format, pw, report, prog.addRow)
+	metrics.ObserveStage(metrics.StageGenerate, genStart)
+	genSpan.SetAttributes(attribute.Int64("generate.rows", prog.rows.Load()))
+	tracing.End(genSpan, genErr)
+	pw.CloseWithError(genErr)
+
+	// Wait for the upload to finish or abort before giving the slot back
+	prog.setStage(models.StageUploading)
+	upload := <-uploaded
+	result.fileSize = prog.bytes.Load()
+
+	if genErr != nil {
+		return result, genErr
+	}
+	if upload.err != nil {
+		return result, upload.err
+	}
+	result.outputPath = upload.path
+
+	// 7. Success State & Metadata
+	finishTime := time.Now()
+
+	// Calculate Expiry
+	duration, _ := time.ParseDuration(report.RetentionPeriod) // e.g. "24h"
+	if duration == 0 {
+		duration = defaults.retention
 	}
+	exp := finishTime.Add(duration)
+	result.expiresAt = &exp
+	return result, nil
 }
 
 func (p *Pool) worker(id int) {
-	log.Printf("Worker %d started", id)
+	lg := slog.With(logging.KeyWorker, id)
+	lg.Debug("Worker started")
 	defer func() {
 		if r := recover(); r != nil {
-			log.Printf("Worker %d panicked: %v. Restarting...", id, r)
+			lg.Error("Worker panicked, restarting", "panic", fmt.Sprint(r))
 			go p.worker(id) // Simple restart
 		}
 	}()
-	for job := range p.JobQueue {
-		log.Printf("Worker %d processing execution %s", id, job.ExecutionID)
-		p.process(job)
+	for {
+		job, ok := p.next(id)
+		if !ok {
+			lg.Debug("Worker stopped")
+			return
+		}
+		p.dispatch(id, job)
 	}
 }
 
-// Add DeliveryTargets to Model
-// Not doing it now to save time/tokens unless requested, assuming minimal viability.
-// But the user requested "Send files via email".
-// So I should pick it up.
-
+// run processes a job and releases its running slot even if it panics.
+func (p *Pool) run(lg *slog.Logger, job models.Job) {
+	defer p.done()
+	defer func() {
+		if r := recover(); r != nil {
+			p.recordPanic(job.ExecutionID, r)
+			panic(r)
+		}
+	}()
+	metrics.ActiveWorkers.Inc()
+	defer metrics.ActiveWorkers.Dec()
+	lg.Info("Processing execution", "priority", queue.Lane(job.Priority), "attempt", attemptOf(job))
+	p.process(lg, job)
+}
@@ -0,0 +1,40 @@
+package executor
+
+import (
+	"testing"
+	"time"
+
+	"rbdb-backend-go/config"
+)
+
+func liveWorkers(p *Pool) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.started)
+}
+
+func waitForWorkers(t *testing.T, p *Pool, want int) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for liveWorkers(p) != want {
+		if time.Now().After(deadline) {
+			t.Fatalf("live workers = %d, want %d", liveWorkers(p), want)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestResize(t *testing.T) {
+	p := NewPool(&config.Config{WorkerCount: 4}, nil, nil)
+	p.Start()
+	waitForWorkers(t, p, 4)
+
+	p.Resize(2)
+	waitForWorkers(t, p, 2)
+	if p.Workers() != 2 {
+		t.Fatalf("Workers() = %d, want 2", p.Workers())
+	}
+
+	p.Resize(5)
+	waitForWorkers(t, p, 5)
+}
@@ -0,0 +1,123 @@
+package executor
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"rbdb-backend-go/internal/models"
+)
+
+const (
+	// progressPublishInterval paces the Redis pub/sub events.
+	progressPublishInterval = time.Second
+	// progressUpdateInterval paces the much heavier control plane updates.
+	progressUpdateInterval = 5 * time.Second
+	// progressUpdateTimeout bounds each of them, retries included, so a
+	// slow control plane can't hold up the execution.
+	progressUpdateTimeout = 3 * time.Second
+)
+
+// progress tracks a running execution. Rows and bytes are written by the
+// generator and upload goroutines while the reporter reads them.
+type progress struct {
+	started time.Time
+	rows    atomic.Int64
+	bytes   atomic.Int64
+
+	mu    sync.Mutex
+	stage string
+}
+
+func newProgress(started time.Time) *progress {
+	return &progress{started: started, stage: models.StageFetchingReport}
+}
+
+func (pr *progress) setStage(stage string) {
+	pr.mu.Lock()
+	pr.stage = stage
+	pr.mu.Unlock()
+}
+
+func (pr *progress) addRow() {
+	pr.rows.Add(1)
+}
+
+func (pr *progress) snapshot() models.ExecutionProgress {
+	pr.mu.Lock()
+	stage := pr.stage
+	pr.mu.Unlock()
+
+	now := time.Now()
+	return models.ExecutionProgress{
+		Stage:          stage,
+		Rows:           pr.rows.Load(),
+		Bytes:          pr.bytes.Load(),
+		ElapsedSeconds: now.Sub(pr.started).Seconds(),
+		UpdatedAt:      now,
+	}
+}
+
+// reportProgress publishes snapshots of job's progress until the returned
+// stop function is called. Control plane updates run in the background, one
+// at a time; a tick that finds the last one still running skips its update.
+// stop cancels the update in flight and waits for it, so none can land
+// after the final status and none holds it up.
+func (p *Pool) reportProgress(ctx context.Context, lg *slog.Logger, job models.Job, pr *progress) (stop func()) {
+	quit := make(chan struct{})
+	exited := make(chan struct{})
+	updateCtx, cancelUpdates := context.WithCancel(ctx)
+	var updates sync.WaitGroup
+	var updating atomic.Bool
+
+	go func() {
+		defer close(exited)
+		ticker := time.NewTicker(progressPublishInterval)
+		defer ticker.Stop()
+
+		var lastUpdate time.Time
+		var last models.ExecutionProgress
+		for {
+			select {
+			case <-quit:
+				return
+			case <-ticker.C:
+			}
+
+			snap := pr.snapshot()
+			if err := p.Source.PublishProgress(context.Background(), job.ExecutionID, snap); err != nil {
+				lg.Warn("Progress publish failed", "error", err)
+			}
+
+			moved := snap.Stage != last.Stage || snap.Rows != last.Rows || snap.Bytes != last.Bytes
+			if !moved || time.Since(lastUpdate) < progressUpdateInterval || !updating.CompareAndSwap(false, true) {
+				continue
+			}
+			lastUpdate, last = time.Now(), snap
+			updates.Add(1)
+			go func() {
+				defer updates.Done()
+				defer updating.Store(false)
+				callCtx, cancel := context.WithTimeout(updateCtx, progressUpdateTimeout)
+				defer cancel()
+				err := p.ApiClient.UpdateExecution(callCtx, job.ExecutionID, models.ExecutionUpdate{
+					Status:   "processing",
+					Attempt:  attemptOf(job),
+					Progress: &snap,
+				})
+				if err != nil && updateCtx.Err() == nil {
+					lg.Warn("Progress update failed", "error", err)
+				}
+			}()
+		}
+	}()
+
+	return func() {
+		close(quit)
+		<-exited
+		cancelUpdates()
+		updates.Wait()
+	}
+}
@@ -0,0 +1,53 @@
+package executor
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+
+	"rbdb-backend-go/config"
+	"rbdb-backend-go/internal/api_client"
+	"rbdb-backend-go/internal/jobsource"
+	"rbdb-backend-go/internal/models"
+	"rbdb-backend-go/internal/queue"
+)
+
+func TestStopProgressDoesNotWaitForAHangingControlPlane(t *testing.T) {
+	var calls atomic.Int32
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		select {
+		case <-r.Context().Done():
+		case <-release:
+		}
+	}))
+	t.Cleanup(srv.Close)
+	t.Cleanup(func() { close(release) })
+
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	client := &api_client.Client{BaseURL: srv.URL, HTTP: srv.Client(), Retries: 3}
+	p := NewPool(&config.Config{WorkerCount: 1}, client, jobsource.NewRedis(queue.New(rdb, "test", "engine-a")))
+
+	job := models.Job{ExecutionID: "exec-1"}
+	stop := p.reportProgress(t.Context(), jobLogger(job), job, newProgress(time.Now()))
+	deadline := time.Now().Add(5 * time.Second)
+	for calls.Load() == 0 && time.Now().Before(deadline) {
+		time.Sleep(20 * time.Millisecond)
+	}
+	if calls.Load() == 0 {
+		t.Fatal("no progress update was sent")
+	}
+
+	stopped := time.Now()
+	stop()
+	if waited := time.Since(stopped); waited > time.Second {
+		t.Fatalf("stop waited %s for the progress update", waited)
+	}
+}
@@ -0,0 +1,206 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"rbdb-backend-go/internal/metrics"
+	"rbdb-backend-go/internal/models"
+)
+
+// reapInterval is how often the reaper looks for stale executions.
+const reapInterval = 30 * time.Second
+
+// errReaped marks executions the reaper settled while they were still
+// running; their worker must not report them again.
+var errReaped = errors.New("execution reaped after exceeding its timeout")
+
+// inflight is an execution this engine reported as processing and hasn't
+// yet given a final status.
+type inflight struct {
+	job     models.Job
+	started time.Time
+	// deadline is the execution timeout plus the stale grace period.
+	deadline time.Time
+	// panicked holds the panic that escaped the worker, if any.
+	panicked string
+	// reported is set once a path claimed its final status, reaped when
+	// that path was the reaper.
+	reported bool
+	reaped   bool
+}
+
+// begin records an execution as in flight until it settles.
+func (p *Pool) begin(job models.Job, started time.Time, timeout time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.inflight[job.ExecutionID] = &inflight{
+		job:      job,
+		started:  started,
+		deadline: started.Add(timeout + p.defaults.staleGrace),
+	}
+}
+
+// settle forgets an execution once it has a final status.
+func (p *Pool) settle(executionID string) {
+	p.mu.Lock()
+	delete(p.inflight, executionID)
+	p.mu.Unlock()
+}
+
+// finish sends the final status the caller claimed and settles the
+// execution.
+func (p *Pool) finish(ctx context.Context, executionID string, update models.ExecutionUpdate) {
+	p.sendStatus(ctx, executionID, update)
+	p.settle(executionID)
+}
+
+// claimFinal reports whether the caller may send the final status of
+// executionID. An execution in flight gets a single one: a cancelled copy
+// parked behind it and its own worker may both try to report it.
+func (p *Pool) claimFinal(executionID string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	f, ok := p.inflight[executionID]
+	if !ok {
+		return true
+	}
+	if f.reported {
+		return false
+	}
+	f.reported = true
+	return true
+}
+
+// claimOwn is claimFinal for the worker running executionID. It also
+// reports whether the reaper took the execution over, in which case the
+// reaper acks the job and decides on its retry.
+func (p *Pool) claimOwn(executionID string) (claimed, reaped bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	f, ok := p.inflight[executionID]
+	switch {
+	case !ok || f.reaped:
+		return false, true
+	case f.reported:
+		return false, false
+	}
+	f.reported = true
+	return true, false
+}
+
+// claimStale is claimFinal for the reaper: it only wins if f is still the
+// entry of its execution, so a worker that reported and settled it since
+// keeps its final status. A worker that panicked while reporting never
+// got its status out and is reaped all the same.
+func (p *Pool) claimStale(f *inflight) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.inflight[f.job.ExecutionID] != f || (f.reported && f.panicked == "") {
+		return false
+	}
+	f.reported, f.reaped = true, true
+	return true
+}
+
+// recordPanic notes a panic that escaped while executionID was running, so
+// the reaper can say why it never finished.
+func (p *Pool) recordPanic(executionID string, r interface{}) {
+	p.mu.Lock()
+	if f, ok := p.inflight[executionID]; ok {
+		f.panicked = fmt.Sprint(r)
+	}
+	p.mu.Unlock()
+}
+
+// reap settles stale executions every reapInterval until the pool quits.
+func (p *Pool) reap() {
+	ticker := time.NewTicker(reapInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-p.quit:
+			return
+		case <-ticker.C:
+			p.reapStale(time.Now())
+		}
+	}
+}
+
+// reapStale settles the executions past their deadline that never got a
+// final status, because their worker panicked or hung. They fail and are
+// retried under their policy; a hung worker is cancelled so it doesn't
+// report them too.
+//
+// Only executions this engine started are on its books. Those of a crashed
+// engine are left to the queue: its jobs are requeued once its heartbeat
+// expires and run again by whoever claims them after their lease lapses.
+func (p *Pool) reapStale(now time.Time) {
+	type staleExecution struct {
+		f      *inflight
+		reason string
+	}
+	p.mu.Lock()
+	var stale []staleExecution
+	for _, f := range p.inflight {
+		if !now.After(f.deadline) {
+			continue
+		}
+		reason := fmt.Sprintf("no final status %s after it started", now.Sub(f.started).Round(time.Second))
+		if f.panicked != "" {
+			reason = "worker panicked: " + f.panicked
+		}
+		stale = append(stale, staleExecution{f, reason})
+	}
+	p.mu.Unlock()
+
+	for _, s := range stale {
+		p.failStale(jobLogger(s.f.job), s.f, s.reason)
+	}
+}
+
+// failStale settles a stale execution as a failed attempt. It does nothing
+// if the worker reported the execution since it was found stale.
+func (p *Pool) failStale(lg *slog.Logger, f *inflight, reason string) {
+	job := f.job
+	if !p.claimStale(f) {
+		return
+	}
+	p.mu.Lock()
+	cancel, running := p.active[job.ExecutionID]
+	p.mu.Unlock()
+	if running {
+		cancel(errReaped)
+	}
+
+	errorLog := "execution reaped: " + reason
+	finishTime := time.Now()
+	job.AttemptHistory = append(job.AttemptHistory, models.AttemptRecord{
+		Attempt:    attemptOf(job),
+		Error:      errorLog,
+		StartedAt:  f.started,
+		FinishedAt: finishTime,
+	})
+
+	status, nextRetryAt := p.retryOrGiveUp(lg, job, nil)
+	update := models.ExecutionUpdate{
+		Status:      status,
+		ErrorLog:    errorLog,
+		Attempt:     attemptOf(job),
+		NextRetryAt: nextRetryAt,
+	}
+	if nextRetryAt == nil {
+		update.FinishedAt = &finishTime
+	}
+	metrics.ExecutionsReaped.WithLabelValues(status).Inc()
+	lg.Warn("Stale execution reaped", "reason", reason, "status", status)
+
+	if status != "retrying" {
+		p.markDone(lg, job)
+	}
+	p.ack(lg, job)
+	p.finish(context.Background(), job.ExecutionID, update)
+}
@@ -0,0 +1,292 @@
+package executor
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+
+	"rbdb-backend-go/config"
+	"rbdb-backend-go/internal/api_client"
+	"rbdb-backend-go/internal/jobsource"
+	"rbdb-backend-go/internal/models"
+	"rbdb-backend-go/internal/queue"
+)
+
+// fakeUpdates records the status updates the control plane receives.
+type fakeUpdates struct {
+	mu      sync.Mutex
+	updates []models.ExecutionUpdate
+}
+
+func (f *fakeUpdates) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var u models.ExecutionUpdate
+	json.NewDecoder(r.Body).Decode(&u)
+	f.updates = append(f.updates, u)
+}
+
+func (f *fakeUpdates) received() []models.ExecutionUpdate {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]models.ExecutionUpdate(nil), f.updates...)
+}
+
+func newReaperPool(t *testing.T) (*Pool, *fakeUpdates) {
+	t.Helper()
+	cp := &fakeUpdates{}
+	srv := httptest.NewServer(cp)
+	t.Cleanup(srv.Close)
+
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	source := jobsource.NewRedis(queue.New(rdb, "test", "engine-a"))
+
+	cfg := &config.Config{WorkerCount: 1, JobTimeout: time.Minute, StaleGrace: time.Minute}
+	client := &api_client.Client{BaseURL: srv.URL, HTTP: srv.Client()}
+	return NewPool(cfg, client, source), cp
+}
+
+func TestReaperLeavesExecutionsWithinGrace(t *testing.T) {
+	p, cp := newReaperPool(t)
+	start := time.Now()
+
+	p.begin(models.Job{ExecutionID: "exec-1"}, start, time.Minute)
+	p.begin(models.Job{ExecutionID: "exec-2"}, start, time.Minute)
+	p.finish(t.Context(), "exec-2", models.ExecutionUpdate{Status: "completed"})
+
+	p.reapStale(start.Add(time.Minute))
+	if got := cp.received(); len(got) != 1 {
+		t.Fatalf("expected only the completed status, got %+v", got)
+	}
+
+	p.reapStale(start.Add(3 * time.Minute))
+	got := cp.received()
+	if len(got) != 2 || got[1].Status != "failed" || !strings.Contains(got[1].ErrorLog, "no final status") {
+		t.Fatalf("expected exec-1 to be reaped, got %+v", got)
+	}
+	if len(p.inflight) != 0 {
+		t.Fatal("reaped execution still in flight")
+	}
+}
+
+func TestReaperFailsPanickedExecutionUnderRetryPolicy(t *testing.T) {
+	tests := []struct {
+		name        string
+		maxAttempts int
+		want        string
+	}{
+		{"retries left", 3, "retrying"},
+		{"out of attempts", 1, "failed"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, cp := newReaperPool(t)
+			job := models.Job{ExecutionID: "exec-1", RetryPolicy: models.RetryPolicy{MaxAttempts: tt.maxAttempts}}
+			start := time.Now()
+
+			p.begin(job, start, time.Minute)
+			p.recordPanic(job.ExecutionID, "nil map")
+			p.reapStale(start.Add(3 * time.Minute))
+
+			got := cp.received()
+			if len(got) != 1 {
+				t.Fatalf("expected one status update, got %+v", got)
+			}
+			if got[0].Status != tt.want || !strings.Contains(got[0].ErrorLog, "worker panicked: nil map") {
+				t.Fatalf("expected %s with the panic as reason, got %+v", tt.want, got[0])
+			}
+			if (got[0].NextRetryAt != nil) != (tt.want == "retrying") {
+				t.Fatalf("unexpected next retry time in %+v", got[0])
+			}
+		})
+	}
+}
+
+func TestCancellingAParkedCopyReportsOnce(t *testing.T) {
+	p, cp := newReaperPool(t)
+	job := models.Job{ExecutionID: "exec-1", ReportID: "r1"}
+
+	p.begin(job, time.Now(), time.Minute)
+	if !p.limits.park(job, slot{source: "ds-1"}) {
+		t.Fatal("the copy should be parked")
+	}
+	p.CancelExecution(t.Context(), job.ExecutionID)
+	if claimed, reaped := p.claimOwn(job.ExecutionID); claimed || reaped {
+		t.Fatalf("the worker should leave the final status alone, got claimed %v, reaped %v", claimed, reaped)
+	}
+	p.settle(job.ExecutionID)
+
+	got := cp.received()
+	if len(got) != 1 || got[0].Status != "cancelled" {
+		t.Fatalf("expected a single cancelled status, got %+v", got)
+	}
+	if len(p.inflight) != 0 {
+		t.Fatal("execution still in flight")
+	}
+}
+
+func TestReaperLeavesCrashedEnginesToQueueRecovery(t *testing.T) {
+	cp := &fakeUpdates{}
+	srv := httptest.NewServer(cp)
+	t.Cleanup(srv.Close)
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	ctx := t.Context()
+
+	// The crashed engine popped the job and claimed it, then went silent.
+	crashed := queue.New(rdb, "test", "engine-dead")
+	mr.RPush("test", `{"execution_id":"exec-1"}`)
+	if _, err := crashed.Pop(ctx, time.Second); err != nil {
+		t.Fatalf("pop: %v", err)
+	}
+	if _, err := crashed.AcquireLease(ctx, "exec-1"); err != nil {
+		t.Fatalf("lease: %v", err)
+	}
+
+	survivor := queue.New(rdb, "test", "engine-a")
+	cfg := &config.Config{WorkerCount: 1, JobTimeout: time.Minute, StaleGrace: time.Minute}
+	client := &api_client.Client{BaseURL: srv.URL, HTTP: srv.Client()}
+	p := NewPool(cfg, client, jobsource.NewRedis(survivor))
+
+	p.reapStale(time.Now().Add(time.Hour))
+	if got := cp.received(); len(got) != 0 {
+		t.Fatalf("the reaper should not settle another engine's executions, got %+v", got)
+	}
+
+	if n, err := survivor.Recover(ctx); err != nil || n != 1 {
+		t.Fatalf("expected the orphaned job to be requeued, got %d, %v", n, err)
+	}
+	if _, err := survivor.AcquireLease(ctx, "exec-1"); !errors.Is(err, queue.ErrLeaseHeld) {
+		t.Fatalf("expected the crashed engine's lease to hold, got %v", err)
+	}
+	mr.FastForward(queue.LeaseTTL)
+	if _, err := survivor.AcquireLease(ctx, "exec-1"); err != nil {
+		t.Fatalf("expected the survivor to claim it once the lease lapsed: %v", err)
+	}
+}
+
+func TestReaperLeavesExecutionsTheirWorkerReported(t *testing.T) {
+	p, cp := newReaperPool(t)
+	start := time.Now()
+	policy := models.RetryPolicy{MaxAttempts: 3}
+
+	// exec-1 finished after the reaper found it stale, exec-2 is being
+	// reported by its worker.
+	p.begin(models.Job{ExecutionID: "exec-1", RetryPolicy: policy}, start, time.Minute)
+	p.begin(models.Job{ExecutionID: "exec-2", RetryPolicy: policy}, start, time.Minute)
+	stale1, stale2 := p.inflight["exec-1"], p.inflight["exec-2"]
+	p.finish(t.Context(), "exec-1", models.ExecutionUpdate{Status: "completed"})
+	if !p.claimFinal("exec-2") {
+		t.Fatal("the worker should get the final status")
+	}
+
+	p.failStale(jobLogger(stale1.job), stale1, "test")
+	p.failStale(jobLogger(stale2.job), stale2, "test")
+	if got := cp.received(); len(got) != 1 || got[0].Status != "completed" {
+		t.Fatalf("expected only the worker's status, got %+v", got)
+	}
+	if p.inflight["exec-2"] == nil {
+		t.Fatal("the reaper settled an execution its worker is reporting")
+	}
+}
+
+func TestWorkerReapedMidRunLeavesTheJobToTheReaper(t *testing.T) {
+	tests := []struct {
+		name string
+		// reap takes the execution over while its report is being fetched.
+		reap         func(p *Pool)
+		wantUpdates  []string
+		wantInFlight int
+		wantRetries  int
+	}{
+		{
+			name:        "cancelled by the reaper",
+			reap:        func(p *Pool) { p.reapStale(time.Now().Add(time.Hour)) },
+			wantUpdates: []string{"processing", "retrying"},
+			wantRetries: 1,
+		},
+		{
+			// The reaper holds the final status but hasn't cancelled the
+			// worker yet; the job stays with it.
+			name: "returning before the cancel",
+			reap: func(p *Pool) {
+				p.mu.Lock()
+				f := p.inflight["exec-1"]
+				p.mu.Unlock()
+				p.claimStale(f)
+			},
+			wantUpdates:  []string{"processing"},
+			wantInFlight: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cp := &fakeUpdates{}
+			fetching := make(chan struct{}, 1)
+			release := make(chan struct{})
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method != http.MethodGet {
+					cp.ServeHTTP(w, r)
+					return
+				}
+				fetching <- struct{}{}
+				select {
+				case <-r.Context().Done():
+				case <-release:
+				}
+				http.NotFound(w, r)
+			}))
+			t.Cleanup(srv.Close)
+
+			mr := miniredis.RunT(t)
+			rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+			source := jobsource.NewRedis(queue.New(rdb, "test", "engine-a"))
+			cfg := &config.Config{WorkerCount: 1, JobTimeout: time.Minute, StaleGrace: time.Minute}
+			p := NewPool(cfg, &api_client.Client{BaseURL: srv.URL, HTTP: srv.Client()}, source)
+
+			mr.RPush("test", `{"execution_id":"exec-1","report_id":"r1","retry_policy":{"max_attempts":3}}`)
+			job, err := source.Next(t.Context(), time.Second)
+			if err != nil {
+				t.Fatalf("next: %v", err)
+			}
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				p.process(jobLogger(job), job)
+			}()
+
+			<-fetching
+			tt.reap(p)
+			close(release)
+			<-done
+
+			var statuses []string
+			for _, u := range cp.received() {
+				statuses = append(statuses, u.Status)
+			}
+			if strings.Join(statuses, ",") != strings.Join(tt.wantUpdates, ",") {
+				t.Fatalf("expected updates %v, got %v", tt.wantUpdates, statuses)
+			}
+			if inFlight, _ := mr.List("test:processing:engine-a"); len(inFlight) != tt.wantInFlight {
+				t.Fatalf("expected %d jobs still held, got %v", tt.wantInFlight, inFlight)
+			}
+			if retries, _ := mr.ZMembers("test:delayed"); len(retries) != tt.wantRetries {
+				t.Fatalf("expected %d retries, got %v", tt.wantRetries, retries)
+			}
+			if mr.Exists("test:done:exec-1") {
+				t.Fatal("the execution should not be marked done")
+			}
+		})
+	}
+}
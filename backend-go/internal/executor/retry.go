@@ -0,0 +1,113 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"rbdb-backend-go/internal/api_client"
+	"rbdb-backend-go/internal/models"
+	"rbdb-backend-go/internal/report_builder"
+)
+
+// retryBaseDelay is the unit the backoff strategies are expressed in.
+const retryBaseDelay = time.Minute
+
+// attemptOf returns the 1-based attempt number of a job.
+func attemptOf(job models.Job) int {
+	if job.Attempt < 1 {
+		return 1
+	}
+	return job.Attempt
+}
+
+// shouldRetry reports whether a failed job has attempts left under its policy.
+func shouldRetry(job models.Job) bool {
+	return attemptOf(job) < job.RetryPolicy.MaxAttempts
+}
+
+// retryDelay computes how long to wait before the given attempt is retried.
+// Strategies: "fixed", "linear" and "exponential" (the default), capped by
+// MaxBackoffHours when set.
+func retryDelay(policy models.RetryPolicy, attempt int) time.Duration {
+	if attempt < 1 {
+		attempt = 1
+	}
+
+	var delay time.Duration
+	switch policy.BackoffStrategy {
+	case "fixed":
+		delay = retryBaseDelay
+	case "linear":
+		delay = retryBaseDelay * time.Duration(attempt)
+	default:
+		// Clamp the shift so large attempt counts can't overflow.
+		shift := attempt - 1
+		if shift > 20 {
+			shift = 20
+		}
+		delay = retryBaseDelay << shift
+	}
+
+	if policy.MaxBackoffHours > 0 {
+		if limit := time.Duration(policy.MaxBackoffHours) * time.Hour; delay > limit {
+			delay = limit
+		}
+	}
+	return delay
+}
+
+// scheduleRetry hands the next attempt of job back to the source and
+// returns when it will run.
+func (p *Pool) scheduleRetry(job models.Job) (time.Time, error) {
+	attempt := attemptOf(job)
+	next := job
+	next.Attempt = attempt + 1
+
+	at := time.Now().Add(retryDelay(job.RetryPolicy, attempt))
+	return at, p.Source.Defer(context.Background(), next, at)
+}
+
+// retryable reports whether an attempt that failed with err may succeed
+// when run again. A report the control plane doesn't have, a request it
+// rejects or invalid parameters won't change by waiting.
+func retryable(err error) bool {
+	return !errors.Is(err, api_client.ErrNotFound) && !errors.Is(err, api_client.ErrValidation) &&
+		!errors.Is(err, report_builder.ErrInvalidParameters)
+}
+
+// retryOrGiveUp schedules the next attempt of a job that failed with cause
+// if its policy allows one and the cause is retryable, or dead-letters it.
+// It returns the status to report and, for a retry, when it will run.
+func (p *Pool) retryOrGiveUp(lg *slog.Logger, job models.Job, cause error) (string, *time.Time) {
+	if !retryable(cause) {
+		lg.Warn("Failure can't be fixed by retrying, giving up", "error", cause)
+	} else if shouldRetry(job) {
+		at, err := p.scheduleRetry(job)
+		if err == nil {
+			lg.Info("Job will retry", "next_retry_at", at.Format(time.RFC3339))
+			return "retrying", &at
+		}
+		lg.Error("Retry scheduling failed", "error", err)
+	}
+
+	errorLog := ""
+	if n := len(job.AttemptHistory); n > 0 {
+		errorLog = job.AttemptHistory[n-1].Error
+	}
+	if id, err := p.deadLetter(job, errorLog); errors.Is(err, errors.ErrUnsupported) {
+		lg.Warn("Job failed for good")
+	} else if err != nil {
+		lg.Error("Dead-lettering failed", "error", err)
+	} else {
+		lg.Warn("Job moved to dead-letter queue", "dead_letter_id", id)
+	}
+	return "failed", nil
+}
+
+// deadLetter stores a job that ran out of attempts, with its failed
+// attempts, for inspection and replay.
+func (p *Pool) deadLetter(job models.Job, errorLog string) (string, error) {
+	return p.Source.DeadLetter(context.Background(), job, errorLog)
+}
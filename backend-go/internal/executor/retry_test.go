@@ -0,0 +1,60 @@
+package executor
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"rbdb-backend-go/internal/api_client"
+	"rbdb-backend-go/internal/models"
+	"rbdb-backend-go/internal/report_builder"
+)
+
+func TestRetryDelay(t *testing.T) {
+	tests := []struct {
+		name     string
+		policy   models.RetryPolicy
+		attempt  int
+		expected time.Duration
+	}{
+		{"fixed", models.RetryPolicy{BackoffStrategy: "fixed"}, 3, time.Minute},
+		{"linear", models.RetryPolicy{BackoffStrategy: "linear"}, 3, 3 * time.Minute},
+		{"exponential first", models.RetryPolicy{BackoffStrategy: "exponential"}, 1, time.Minute},
+		{"exponential third", models.RetryPolicy{BackoffStrategy: "exponential"}, 3, 4 * time.Minute},
+		{"unknown falls back to exponential", models.RetryPolicy{}, 2, 2 * time.Minute},
+		{"capped", models.RetryPolicy{BackoffStrategy: "exponential", MaxBackoffHours: 1}, 10, time.Hour},
+		{"huge attempt stays capped", models.RetryPolicy{MaxBackoffHours: 24}, 500, 24 * time.Hour},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := retryDelay(tt.policy, tt.attempt); got != tt.expected {
+				t.Errorf("expected %s, got %s", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestShouldRetry(t *testing.T) {
+	job := models.Job{RetryPolicy: models.RetryPolicy{MaxAttempts: 3}}
+	if !shouldRetry(job) {
+		t.Error("first attempt of 3 should retry")
+	}
+	job.Attempt = 3
+	if shouldRetry(job) {
+		t.Error("last attempt must not retry")
+	}
+}
+
+func TestRetryable(t *testing.T) {
+	if !retryable(errors.New("connection refused")) || !retryable(api_client.ErrUnavailable) {
+		t.Error("transient failures should be retried")
+	}
+	if retryable(fmt.Errorf("report r1: %w", api_client.ErrNotFound)) {
+		t.Error("a missing report must not be retried")
+	}
+	if retryable(fmt.Errorf("%w: missing required start", report_builder.ErrInvalidParameters)) {
+		t.Error("invalid parameters must not be retried")
+	}
+}
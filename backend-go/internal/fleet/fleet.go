@@ -0,0 +1,71 @@
+// Package fleet keeps the control plane informed about the engine: it
+// registers at startup and then sends heartbeats listing what it runs.
+package fleet
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"rbdb-backend-go/internal/api_client"
+	"rbdb-backend-go/internal/models"
+)
+
+// Reporter registers the engine and sends its heartbeats.
+type Reporter struct {
+	Client   *api_client.Client
+	Info     models.EngineRegistration
+	Interval time.Duration
+	// State returns the engine's state for the next heartbeat.
+	State func() models.EngineHeartbeat
+}
+
+// Run registers the engine and sends a heartbeat every Interval until ctx
+// is done. A failed registration is retried in place of the next
+// heartbeat, as is one the control plane lost track of.
+func (r *Reporter) Run(ctx context.Context) {
+	registered := r.register(ctx)
+
+	ticker := time.NewTicker(r.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if !registered {
+			registered = r.register(ctx)
+			continue
+		}
+		err := r.Client.SendHeartbeat(ctx, r.Info.EngineID, r.State())
+		switch {
+		case errors.Is(err, api_client.ErrEngineUnknown):
+			slog.Warn("Control plane doesn't know this engine, registering again")
+			registered = r.register(ctx)
+		case err != nil && ctx.Err() == nil:
+			slog.Warn("Engine heartbeat failed", "error", err)
+		}
+	}
+}
+
+func (r *Reporter) register(ctx context.Context) bool {
+	if err := r.Client.RegisterEngine(ctx, r.Info); err != nil {
+		if ctx.Err() == nil {
+			slog.Warn("Engine registration failed", "error", err)
+		}
+		return false
+	}
+	slog.Info("Engine registered with the control plane", "version", r.Info.Version, "hostname", r.Info.Hostname)
+	return true
+}
+
+// Stop sends a last heartbeat marking the engine stopped, so the control
+// plane doesn't wait for missed heartbeats to notice.
+func (r *Reporter) Stop(ctx context.Context) error {
+	hb := r.State()
+	hb.Status = models.EngineStopped
+	return r.Client.SendHeartbeat(ctx, r.Info.EngineID, hb)
+}
@@ -0,0 +1,98 @@
+package fleet
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"rbdb-backend-go/internal/api_client"
+	"rbdb-backend-go/internal/models"
+)
+
+// fakeControlPlane records registrations and heartbeats. It forgets the
+// engine once, after the first heartbeat, as after a control plane reset.
+type fakeControlPlane struct {
+	mu            sync.Mutex
+	registrations int
+	heartbeats    []models.EngineHeartbeat
+	forgotten     bool
+}
+
+func (f *fakeControlPlane) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	switch r.URL.Path {
+	case "/engines/register":
+		f.registrations++
+	case "/engines/engine-a/heartbeat":
+		var hb models.EngineHeartbeat
+		json.NewDecoder(r.Body).Decode(&hb)
+		f.heartbeats = append(f.heartbeats, hb)
+		if !f.forgotten {
+			f.forgotten = true
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{"success": true, "data": nil})
+}
+
+func (f *fakeControlPlane) counts() (int, int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.registrations, len(f.heartbeats)
+}
+
+func TestReporterRegistersAgainWhenForgotten(t *testing.T) {
+	cp := &fakeControlPlane{}
+	srv := httptest.NewServer(cp)
+	defer srv.Close()
+
+	r := &Reporter{
+		Client:   &api_client.Client{BaseURL: srv.URL, HTTP: srv.Client()},
+		Info:     models.EngineRegistration{EngineID: "engine-a"},
+		Interval: 5 * time.Millisecond,
+		State: func() models.EngineHeartbeat {
+			return models.EngineHeartbeat{Status: models.EngineRunning, ActiveExecutions: []string{"exec-1"}}
+		},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		r.Run(ctx)
+	}()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		if regs, beats := cp.counts(); regs >= 2 && beats >= 2 {
+			break
+		}
+		if time.Now().After(deadline) {
+			regs, beats := cp.counts()
+			t.Fatalf("expected a second registration and heartbeats, got %d and %d", regs, beats)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	cancel()
+	<-done
+
+	if err := r.Stop(context.Background()); err != nil {
+		t.Fatalf("stop: %v", err)
+	}
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+	last := cp.heartbeats[len(cp.heartbeats)-1]
+	if last.Status != models.EngineStopped || len(last.ActiveExecutions) != 1 {
+		t.Fatalf("expected a final stopped heartbeat, got %+v", last)
+	}
+}
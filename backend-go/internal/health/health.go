@@ -0,0 +1,82 @@
+// Package health serves the engine's liveness and readiness probes.
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// checkTimeout bounds each dependency check so a hung dependency can't hang
+// the probe.
+const checkTimeout = 2 * time.Second
+
+// Check reports whether a dependency is usable; a nil error means ready.
+type Check struct {
+	Name string
+	Run  func(ctx context.Context) error
+}
+
+type checkResult struct {
+	Status  string `json:"status"`
+	Message string `json:"message,omitempty"`
+}
+
+// Liveness answers 200 as long as the process can serve HTTP.
+func Liveness() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"status":    "ok",
+			"timestamp": time.Now().UTC().Format(time.RFC3339),
+		})
+	})
+}
+
+// Readiness runs all checks concurrently and answers 200 only if every one
+// passes, 503 otherwise. The body lists each check's result.
+func Readiness(checks ...Check) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), checkTimeout)
+		defer cancel()
+
+		results := make(map[string]checkResult, len(checks))
+		var mu sync.Mutex
+		var wg sync.WaitGroup
+		for _, c := range checks {
+			wg.Add(1)
+			go func(c Check) {
+				defer wg.Done()
+				res := checkResult{Status: "ok"}
+				if err := c.Run(ctx); err != nil {
+					res = checkResult{Status: "error", Message: err.Error()}
+				}
+				mu.Lock()
+				results[c.Name] = res
+				mu.Unlock()
+			}(c)
+		}
+		wg.Wait()
+
+		status, code := "ready", http.StatusOK
+		for _, res := range results {
+			if res.Status != "ok" {
+				status, code = "not_ready", http.StatusServiceUnavailable
+				break
+			}
+		}
+		writeJSON(w, code, map[string]interface{}{
+			"status":    status,
+			"checks":    results,
+			"timestamp": time.Now().UTC().Format(time.RFC3339),
+		})
+	})
+}
+
+func writeJSON(w http.ResponseWriter, code int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,38 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestReadiness(t *testing.T) {
+	ok := Check{Name: "redis", Run: func(context.Context) error { return nil }}
+	down := Check{Name: "control_plane", Run: func(context.Context) error { return errors.New("connection refused") }}
+
+	rec := httptest.NewRecorder()
+	Readiness(ok).ServeHTTP(rec, httptest.NewRequest("GET", "/readyz", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200 with passing checks, got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	Readiness(ok, down).ServeHTTP(rec, httptest.NewRequest("GET", "/readyz", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503 with a failing check, got %d", rec.Code)
+	}
+
+	var body struct {
+		Status string                 `json:"status"`
+		Checks map[string]checkResult `json:"checks"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if body.Status != "not_ready" || body.Checks["redis"].Status != "ok" || body.Checks["control_plane"].Message != "connection refused" {
+		t.Fatalf("unexpected body: %+v", body)
+	}
+}
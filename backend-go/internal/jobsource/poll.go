@@ -0,0 +1,291 @@
+package jobsource
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	"rbdb-backend-go/internal/api_client"
+	"rbdb-backend-go/internal/logging"
+	"rbdb-backend-go/internal/models"
+	"rbdb-backend-go/internal/queue"
+)
+
+// Poll claims jobs from the control plane over HTTP, for sites without
+// Redis. Each job is held under a claim that the engine renews while the
+// job waits or runs; a claim that isn't renewed for the lease period goes
+// to another engine.
+type Poll struct {
+	client   *api_client.Client
+	engineID string
+	interval time.Duration
+	lease    time.Duration
+
+	mu sync.Mutex
+	// held maps the execution IDs this engine holds to their claims.
+	held map[string]*heldClaim
+}
+
+type heldClaim struct {
+	token     string
+	cancelled bool
+	// lost is set while a worker runs the job.
+	lost func(error)
+}
+
+// NewPoll returns a source that asks the control plane for a job every
+// interval while idle and holds each claim for lease between renewals.
+func NewPoll(client *api_client.Client, engineID string, interval, lease time.Duration) *Poll {
+	return &Poll{
+		client:   client,
+		engineID: engineID,
+		interval: interval,
+		lease:    lease,
+		held:     make(map[string]*heldClaim),
+	}
+}
+
+// Next claims one job. Claiming one at a time leaves the rest to engines
+// with idle workers.
+func (s *Poll) Next(ctx context.Context, timeout time.Duration) (models.Job, error) {
+	claims, err := s.client.ClaimExecutions(ctx, s.engineID, 1, s.lease)
+	if err != nil {
+		return models.Job{}, err
+	}
+	if len(claims) == 0 {
+		wait := s.interval
+		if timeout < wait {
+			wait = timeout
+		}
+		select {
+		case <-ctx.Done():
+		case <-time.After(wait):
+		}
+		return models.Job{}, ErrEmpty
+	}
+
+	c := claims[0]
+	var job models.Job
+	if err := json.Unmarshal(c.Payload, &job); err != nil {
+		s.reject(ctx, c, err)
+		return models.Job{}, ErrEmpty
+	}
+	if job.ExecutionID == "" {
+		job.ExecutionID = c.ExecutionID
+	}
+	job.Receipt = models.Receipt{Lane: queue.Lane(job.Priority), Payload: string(c.Payload), ID: c.Token}
+
+	s.mu.Lock()
+	s.held[c.ExecutionID] = &heldClaim{token: c.Token}
+	s.mu.Unlock()
+	return job, nil
+}
+
+// reject fails an execution whose payload can't be parsed. Without a
+// dead-letter store the failed execution itself keeps the payload.
+func (s *Poll) reject(ctx context.Context, c api_client.Claim, parseErr error) {
+	lg := slog.With(logging.KeyExecutionID, c.ExecutionID)
+	lg.Error("Unparseable payload", "error", parseErr, "payload", string(c.Payload))
+
+	finishTime := time.Now()
+	if err := s.client.UpdateExecution(ctx, c.ExecutionID, models.ExecutionUpdate{
+		Status:     "failed",
+		FinishedAt: &finishTime,
+		ErrorLog:   "unparseable job payload: " + parseErr.Error(),
+	}); err != nil {
+		lg.Error("Reporting unparseable payload failed", "error", err)
+	}
+	if err := s.client.ReleaseClaim(ctx, c.ExecutionID, api_client.ClaimRelease{Token: c.Token, Done: true}); err != nil {
+		lg.Error("Releasing unparseable job failed", "error", err)
+	}
+}
+
+// Claim checks the job is still held; the claim taken by Next doubles as
+// the execution lease.
+func (s *Poll) Claim(ctx context.Context, job models.Job) (Lease, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if h, ok := s.held[job.ExecutionID]; !ok || h.token != job.Receipt.ID {
+		return nil, ErrClaimed
+	}
+	return &pollLease{source: s, executionID: job.ExecutionID, token: job.Receipt.ID}, nil
+}
+
+func (s *Poll) Ack(ctx context.Context, job models.Job) error {
+	_, err := s.release(ctx, job, api_client.ClaimRelease{Done: true})
+	return err
+}
+
+func (s *Poll) Requeue(ctx context.Context, job models.Job) (bool, error) {
+	return s.release(ctx, job, api_client.ClaimRelease{})
+}
+
+// Defer hands the claim back with job as the new payload, to be claimed
+// again from at. The ack that follows finds nothing left to settle.
+func (s *Poll) Defer(ctx context.Context, job models.Job, at time.Time) error {
+	payload, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+	ok, err := s.release(ctx, job, api_client.ClaimRelease{Payload: payload, AvailableAt: &at})
+	if err == nil && !ok {
+		err = api_client.ErrClaimLost
+	}
+	return err
+}
+
+// release settles a held claim. It reports false if the claim was no
+// longer held.
+func (s *Poll) release(ctx context.Context, job models.Job, release api_client.ClaimRelease) (bool, error) {
+	s.mu.Lock()
+	h, ok := s.held[job.ExecutionID]
+	if ok && h.token == job.Receipt.ID {
+		delete(s.held, job.ExecutionID)
+	}
+	s.mu.Unlock()
+	if !ok || h.token != job.Receipt.ID {
+		return false, nil
+	}
+
+	release.Token = h.token
+	err := s.client.ReleaseClaim(ctx, job.ExecutionID, release)
+	if errors.Is(err, api_client.ErrClaimLost) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// DeadLetter is not supported: the control plane keeps the failed
+// execution with its error log instead.
+func (s *Poll) DeadLetter(ctx context.Context, job models.Job, errorLog string) (string, error) {
+	return "", errors.ErrUnsupported
+}
+
+// MarkDone does nothing; the control plane stops handing out an execution
+// once it reports a final status.
+func (s *Poll) MarkDone(ctx context.Context, executionID string) error {
+	return nil
+}
+
+// IsCancelled reports a cancellation seen on the last renewal.
+func (s *Poll) IsCancelled(ctx context.Context, executionID string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	h, ok := s.held[executionID]
+	return ok && h.cancelled, nil
+}
+
+// RemoveQueued does nothing; the control plane drops unclaimed jobs itself
+// when an execution is cancelled.
+func (s *Poll) RemoveQueued(ctx context.Context, executionID string) (int, error) {
+	return 0, nil
+}
+
+// PublishProgress does nothing; progress reaches the control plane with
+// the status updates.
+func (s *Poll) PublishProgress(ctx context.Context, executionID string, p models.ExecutionProgress) error {
+	return nil
+}
+
+// Maintain renews every held claim three times per lease period.
+func (s *Poll) Maintain(ctx context.Context) {
+	ticker := time.NewTicker(s.lease / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.renew(ctx)
+		}
+	}
+}
+
+func (s *Poll) renew(ctx context.Context) {
+	s.mu.Lock()
+	tokens := make(map[string]string, len(s.held))
+	for id, h := range s.held {
+		tokens[id] = h.token
+	}
+	s.mu.Unlock()
+
+	for id, token := range tokens {
+		cancelled, err := s.client.RenewClaim(ctx, id, token, s.lease)
+		switch {
+		case errors.Is(err, api_client.ErrClaimLost):
+			slog.Warn("Execution claim lost", logging.KeyExecutionID, id)
+			s.signal(id, token, ErrLeaseLost)
+		case err != nil:
+			// Retried on the next tick; the claim survives until it expires.
+			slog.Warn("Claim renewal failed", logging.KeyExecutionID, id, "error", err)
+		case cancelled:
+			s.signal(id, token, ErrCancelled)
+		}
+	}
+}
+
+// signal records what happened to a claim and tells the worker running it.
+// A lost claim is forgotten; a cancelled one stays held until the worker
+// settles it.
+func (s *Poll) signal(id, token string, reason error) {
+	s.mu.Lock()
+	h, ok := s.held[id]
+	if !ok || h.token != token {
+		s.mu.Unlock()
+		return
+	}
+	if errors.Is(reason, ErrLeaseLost) {
+		delete(s.held, id)
+	} else {
+		h.cancelled = true
+	}
+	lost := h.lost
+	h.lost = nil
+	s.mu.Unlock()
+
+	if lost != nil {
+		lost(reason)
+	}
+}
+
+type pollLease struct {
+	source      *Poll
+	executionID string
+	token       string
+}
+
+// KeepAlive registers the running worker for renewal outcomes until ctx is
+// done; the renewals themselves happen in Maintain.
+func (l *pollLease) KeepAlive(ctx context.Context, lost func(error)) {
+	s := l.source
+	s.mu.Lock()
+	h, ok := s.held[l.executionID]
+	switch {
+	case !ok || h.token != l.token:
+		s.mu.Unlock()
+		lost(ErrLeaseLost)
+		return
+	case h.cancelled:
+		s.mu.Unlock()
+		lost(ErrCancelled)
+		return
+	}
+	h.lost = lost
+	s.mu.Unlock()
+
+	<-ctx.Done()
+
+	s.mu.Lock()
+	if h, ok := s.held[l.executionID]; ok && h.token == l.token {
+		h.lost = nil
+	}
+	s.mu.Unlock()
+}
+
+// Release does nothing; the claim is settled by Ack, Requeue or Defer.
+func (l *pollLease) Release(ctx context.Context) error {
+	return nil
+}
@@ -0,0 +1,181 @@
+package jobsource
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"rbdb-backend-go/internal/api_client"
+)
+
+// fakeControlPlane serves the claim endpoints for one queued execution.
+type fakeControlPlane struct {
+	mu        sync.Mutex
+	payload   string
+	token     string
+	cancelled bool
+	releases  []api_client.ClaimRelease
+}
+
+func (f *fakeControlPlane) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var body api_client.ClaimRelease
+	json.NewDecoder(r.Body).Decode(&body)
+	reply := func(data interface{}) {
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": true, "data": data})
+	}
+
+	switch {
+	case r.URL.Path == "/executions/claim":
+		if f.payload == "" || f.token != "" {
+			reply([]api_client.Claim{})
+			return
+		}
+		f.token = "token-1"
+		reply([]api_client.Claim{{ExecutionID: "exec-1", Token: f.token, Payload: json.RawMessage(f.payload)}})
+	case body.Token != f.token:
+		w.WriteHeader(http.StatusConflict)
+	case strings.HasSuffix(r.URL.Path, "/claim/renew"):
+		reply(map[string]bool{"cancelled": f.cancelled})
+	case strings.HasSuffix(r.URL.Path, "/claim/release"):
+		f.releases = append(f.releases, body)
+		f.token = ""
+		reply(nil)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func newTestPoll(t *testing.T, payload string) (*Poll, *fakeControlPlane) {
+	t.Helper()
+	cp := &fakeControlPlane{payload: payload}
+	srv := httptest.NewServer(cp)
+	t.Cleanup(srv.Close)
+	client := &api_client.Client{BaseURL: srv.URL, HTTP: srv.Client()}
+	return NewPoll(client, "engine-a", 10*time.Millisecond, 30*time.Second), cp
+}
+
+func TestPollClaimAndAck(t *testing.T) {
+	ctx := context.Background()
+	s, cp := newTestPoll(t, `{"job_id":"job-1","report_id":"report-1","priority":"high"}`)
+
+	job, err := s.Next(ctx, time.Second)
+	if err != nil {
+		t.Fatalf("next: %v", err)
+	}
+	if job.ExecutionID != "exec-1" || job.Receipt.Lane != "high" || job.Receipt.ID != "token-1" {
+		t.Fatalf("unexpected job %+v", job)
+	}
+	if _, err := s.Next(ctx, 10*time.Millisecond); !errors.Is(err, ErrEmpty) {
+		t.Fatalf("expected no second job, got %v", err)
+	}
+
+	if _, err := s.Claim(ctx, job); err != nil {
+		t.Fatalf("claim: %v", err)
+	}
+	if err := s.Ack(ctx, job); err != nil {
+		t.Fatalf("ack: %v", err)
+	}
+	if len(cp.releases) != 1 || !cp.releases[0].Done {
+		t.Fatalf("expected one done release, got %+v", cp.releases)
+	}
+}
+
+func TestPollDeferThenAck(t *testing.T) {
+	ctx := context.Background()
+	s, cp := newTestPoll(t, `{"job_id":"job-1","execution_id":"exec-1"}`)
+
+	job, err := s.Next(ctx, time.Second)
+	if err != nil {
+		t.Fatalf("next: %v", err)
+	}
+	next := job
+	next.Attempt = 2
+	if err := s.Defer(ctx, next, time.Now().Add(time.Minute)); err != nil {
+		t.Fatalf("defer: %v", err)
+	}
+	// The deferred claim is already settled, so the ack must not drop it.
+	if err := s.Ack(ctx, job); err != nil {
+		t.Fatalf("ack: %v", err)
+	}
+
+	if len(cp.releases) != 1 {
+		t.Fatalf("expected one release, got %+v", cp.releases)
+	}
+	rel := cp.releases[0]
+	if rel.Done || rel.AvailableAt == nil || !strings.Contains(string(rel.Payload), `"attempt":2`) {
+		t.Fatalf("expected a deferred release with the next attempt, got %+v", rel)
+	}
+}
+
+func TestPollRenewalSignalsRunningJob(t *testing.T) {
+	ctx := context.Background()
+	s, cp := newTestPoll(t, `{"job_id":"job-1","execution_id":"exec-1"}`)
+
+	job, err := s.Next(ctx, time.Second)
+	if err != nil {
+		t.Fatalf("next: %v", err)
+	}
+	lease, err := s.Claim(ctx, job)
+	if err != nil {
+		t.Fatalf("claim: %v", err)
+	}
+
+	causes := make(chan error, 1)
+	runCtx, stop := context.WithCancel(ctx)
+	defer stop()
+	go lease.KeepAlive(runCtx, func(err error) { causes <- err })
+
+	cp.mu.Lock()
+	cp.cancelled = true
+	cp.mu.Unlock()
+	// Renew until the worker has registered for the outcome.
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		s.renew(ctx)
+		select {
+		case cause := <-causes:
+			if !errors.Is(cause, ErrCancelled) {
+				t.Fatalf("expected cancellation, got %v", cause)
+			}
+			if cancelled, _ := s.IsCancelled(ctx, "exec-1"); !cancelled {
+				t.Fatal("cancellation not recorded")
+			}
+			return
+		case <-time.After(10 * time.Millisecond):
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("running job was not told about the cancellation")
+		}
+	}
+}
+
+func TestPollLostClaimIsNotRun(t *testing.T) {
+	ctx := context.Background()
+	s, cp := newTestPoll(t, `{"job_id":"job-1","execution_id":"exec-1"}`)
+
+	job, err := s.Next(ctx, time.Second)
+	if err != nil {
+		t.Fatalf("next: %v", err)
+	}
+	// The claim expired and went to another engine.
+	cp.mu.Lock()
+	cp.token = "token-2"
+	cp.mu.Unlock()
+	s.renew(ctx)
+
+	if _, err := s.Claim(ctx, job); !errors.Is(err, ErrClaimed) {
+		t.Fatalf("expected ErrClaimed, got %v", err)
+	}
+	if ok, err := s.Requeue(ctx, job); ok || err != nil {
+		t.Fatalf("expected nothing to requeue, got %v, %v", ok, err)
+	}
+}
@@ -0,0 +1,160 @@
+package jobsource
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"time"
+
+	"rbdb-backend-go/internal/logging"
+	"rbdb-backend-go/internal/models"
+	"rbdb-backend-go/internal/queue"
+)
+
+// Redis takes jobs from the Redis queue, in list or stream mode.
+type Redis struct {
+	Queue *queue.Queue
+}
+
+// NewRedis returns a source reading from q.
+func NewRedis(q *queue.Queue) *Redis {
+	return &Redis{Queue: q}
+}
+
+// Next pops the next job. Payloads that can't be parsed are moved to the
+// dead-letter queue and skipped.
+func (s *Redis) Next(ctx context.Context, timeout time.Duration) (models.Job, error) {
+	receipt, err := s.Queue.Pop(ctx, timeout)
+	if errors.Is(err, queue.ErrEmpty) {
+		return models.Job{}, ErrEmpty
+	}
+	if err != nil {
+		return models.Job{}, err
+	}
+
+	var job models.Job
+	if err := json.Unmarshal([]byte(receipt.Payload), &job); err != nil {
+		slog.Error("Unparseable payload", "error", err, "lane", receipt.Lane, "payload", receipt.Payload)
+		id, dlqErr := s.Queue.DeadLetter(ctx, queue.DeadLetter{
+			Reason:  queue.ReasonUnparseable,
+			Error:   err.Error(),
+			Lane:    receipt.Lane,
+			Payload: receipt.Payload,
+		})
+		if dlqErr != nil {
+			// Leave it in the processing list; the next startup sweep retries.
+			slog.Error("Dead-lettering unparseable payload failed", "error", dlqErr)
+			return models.Job{}, ErrEmpty
+		}
+		slog.Warn("Unparseable payload moved to dead-letter queue", "dead_letter_id", id)
+		_ = s.Queue.Ack(ctx, receipt)
+		return models.Job{}, ErrEmpty
+	}
+	job.Receipt = receipt
+	return job, nil
+}
+
+// Claim takes the execution lease. The done marker is checked under the
+// lease so a run finishing concurrently can't slip by.
+func (s *Redis) Claim(ctx context.Context, job models.Job) (Lease, error) {
+	lease, err := s.Queue.AcquireLease(ctx, job.ExecutionID)
+	if errors.Is(err, queue.ErrLeaseHeld) {
+		return nil, ErrClaimed
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	done, err := s.Queue.IsDone(ctx, job.ExecutionID)
+	if err != nil {
+		slog.Warn("Done check failed", logging.KeyExecutionID, job.ExecutionID, "error", err)
+	}
+	if done {
+		if err := lease.Release(ctx); err != nil {
+			slog.Warn("Lease release failed", logging.KeyExecutionID, job.ExecutionID, "error", err)
+		}
+		return nil, ErrFinished
+	}
+	return redisLease{lease}, nil
+}
+
+func (s *Redis) Ack(ctx context.Context, job models.Job) error {
+	return s.Queue.Ack(ctx, job.Receipt)
+}
+
+func (s *Redis) Requeue(ctx context.Context, job models.Job) (bool, error) {
+	return s.Queue.Requeue(ctx, job.Receipt)
+}
+
+// Defer stores job in the delayed set of the lane it came from.
+func (s *Redis) Defer(ctx context.Context, job models.Job, at time.Time) error {
+	payload, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+	return s.Queue.Schedule(ctx, laneOf(job), string(payload), at)
+}
+
+// DeadLetter stores a job that ran out of attempts. The stored payload is
+// reset to a fresh first attempt so a replay gets the full retry policy
+// again; the failed attempts are kept on the entry itself.
+func (s *Redis) DeadLetter(ctx context.Context, job models.Job, errorLog string) (string, error) {
+	fresh := job
+	fresh.Attempt = 0
+	fresh.AttemptHistory = nil
+
+	payload, err := json.Marshal(fresh)
+	if err != nil {
+		return "", err
+	}
+
+	return s.Queue.DeadLetter(ctx, queue.DeadLetter{
+		Reason:      queue.ReasonRetriesExhausted,
+		Error:       errorLog,
+		Lane:        laneOf(job),
+		ExecutionID: job.ExecutionID,
+		ReportID:    job.ReportID,
+		Payload:     string(payload),
+		Attempts:    job.AttemptHistory,
+	})
+}
+
+func (s *Redis) MarkDone(ctx context.Context, executionID string) error {
+	return s.Queue.MarkDone(ctx, executionID)
+}
+
+func (s *Redis) IsCancelled(ctx context.Context, executionID string) (bool, error) {
+	return s.Queue.IsCancelled(ctx, executionID)
+}
+
+func (s *Redis) RemoveQueued(ctx context.Context, executionID string) (int, error) {
+	removed, err := s.Queue.RemoveQueued(ctx, executionID)
+	return len(removed), err
+}
+
+func (s *Redis) PublishProgress(ctx context.Context, executionID string, p models.ExecutionProgress) error {
+	return s.Queue.PublishProgress(ctx, executionID, p)
+}
+
+// Maintain runs the queue's heartbeat, sweeps and promotions.
+func (s *Redis) Maintain(ctx context.Context) {
+	s.Queue.Maintain(ctx)
+}
+
+// laneOf returns the lane a job was taken from, or the one its priority
+// maps to for jobs that didn't come through the queue.
+func laneOf(job models.Job) string {
+	if job.Receipt.Lane != "" {
+		return job.Receipt.Lane
+	}
+	return queue.Lane(job.Priority)
+}
+
+type redisLease struct {
+	*queue.Lease
+}
+
+func (l redisLease) KeepAlive(ctx context.Context, lost func(error)) {
+	l.Lease.KeepAlive(ctx, func() { lost(ErrLeaseLost) })
+}
@@ -0,0 +1,73 @@
+// Package jobsource abstracts where the engine takes its jobs from: the
+// Redis queue, in list or stream mode, or the control plane over HTTP.
+package jobsource
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"rbdb-backend-go/internal/models"
+)
+
+var (
+	// ErrEmpty is returned by Next when no job arrived in time.
+	ErrEmpty = errors.New("jobsource: no job available")
+	// ErrClaimed is returned by Claim when another worker is running the
+	// execution.
+	ErrClaimed = errors.New("jobsource: execution claimed by another worker")
+	// ErrFinished is returned by Claim when the execution already reached a
+	// final state.
+	ErrFinished = errors.New("jobsource: execution already finished")
+	// ErrLeaseLost is passed to a lease's lost callback when the execution
+	// may have been taken over.
+	ErrLeaseLost = errors.New("jobsource: execution lease lost")
+	// ErrCancelled is passed to a lease's lost callback when an operator
+	// cancelled the execution.
+	ErrCancelled = errors.New("jobsource: execution cancelled")
+)
+
+// JobSource hands jobs to the engine and takes them back once processed.
+// A job stays with the engine from Next until it is acked, requeued or
+// deferred; if the engine dies in between, the source gives it to another
+// engine.
+type JobSource interface {
+	// Next waits up to timeout for a job and returns ErrEmpty if none came.
+	Next(ctx context.Context, timeout time.Duration) (models.Job, error)
+	// Claim gives the caller exclusive use of the job's execution until
+	// the lease is released.
+	Claim(ctx context.Context, job models.Job) (Lease, error)
+	// Ack settles a job for good.
+	Ack(ctx context.Context, job models.Job) error
+	// Requeue hands a job back to be run as soon as possible. It reports
+	// false if the job was no longer held by this engine.
+	Requeue(ctx context.Context, job models.Job) (bool, error)
+	// Defer stores job to be run again from at; the caller still acks the
+	// job it took.
+	Defer(ctx context.Context, job models.Job, at time.Time) error
+	// DeadLetter keeps a job that failed for good for inspection and
+	// replay, returning the entry's ID. Sources without such a store
+	// return errors.ErrUnsupported.
+	DeadLetter(ctx context.Context, job models.Job, errorLog string) (string, error)
+	// MarkDone records that an execution reached a final state, so late
+	// duplicates are skipped.
+	MarkDone(ctx context.Context, executionID string) error
+	// IsCancelled reports whether an operator asked to cancel an execution.
+	IsCancelled(ctx context.Context, executionID string) (bool, error)
+	// RemoveQueued drops the jobs of an execution that no engine has taken
+	// yet and returns how many were removed.
+	RemoveQueued(ctx context.Context, executionID string) (int, error)
+	// PublishProgress shares a running execution's progress with watchers.
+	PublishProgress(ctx context.Context, executionID string, p models.ExecutionProgress) error
+	// Maintain runs the source's background upkeep until ctx is done.
+	Maintain(ctx context.Context)
+}
+
+// Lease is an exclusive claim on a running execution.
+type Lease interface {
+	// KeepAlive renews the lease until ctx is done. lost is called once,
+	// with ErrLeaseLost or ErrCancelled, when the holder must stop.
+	KeepAlive(ctx context.Context, lost func(error))
+	// Release gives the lease up.
+	Release(ctx context.Context) error
+}
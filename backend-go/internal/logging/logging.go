@@ -0,0 +1,140 @@
+// Package logging sets up the engine's structured logger. Every record
+// passes through a redacting handler, so secrets never reach the output even
+// when they hide inside driver errors.
+package logging
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// Correlation keys attached to execution log lines.
+const (
+	KeyExecutionID  = "execution_id"
+	KeyReportID     = "report_id"
+	KeyJobID        = "job_id"
+	KeyWorker       = "worker"
+	KeyDataSourceID = "data_source_id"
+)
+
+// Setup installs a JSON (or, with format "text", logfmt) logger at the given
+// level as the slog and standard library default, and returns it.
+func Setup(level, format string) *slog.Logger {
+	return setup(os.Stdout, level, format)
+}
+
+// logLevel is shared by every logger Setup creates, so SetLevel takes
+// effect immediately.
+var logLevel = new(slog.LevelVar)
+
+func setup(w io.Writer, level, format string) *slog.Logger {
+	logLevel.Set(ParseLevel(level))
+	opts := &slog.HandlerOptions{Level: logLevel}
+
+	var h slog.Handler
+	if strings.EqualFold(format, "text") {
+		h = slog.NewTextHandler(w, opts)
+	} else {
+		h = slog.NewJSONHandler(w, opts)
+	}
+
+	logger := slog.New(&redactingHandler{next: h})
+	slog.SetDefault(logger)
+	return logger
+}
+
+// SetLevel changes the level of the logger installed by Setup.
+func SetLevel(level string) {
+	logLevel.Set(ParseLevel(level))
+}
+
+// ParseLevel maps debug, info, warn and error to slog levels, defaulting to
+// info.
+func ParseLevel(level string) slog.Level {
+	switch strings.ToLower(strings.TrimSpace(level)) {
+	case "debug":
+		return slog.LevelDebug
+	case "warn", "warning":
+		return slog.LevelWarn
+	case "error":
+		return slog.LevelError
+	default:
+		return slog.LevelInfo
+	}
+}
+
+type ctxKey struct{}
+
+// NewContext returns a copy of ctx carrying logger.
+func NewContext(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, ctxKey{}, logger)
+}
+
+// FromContext returns the logger stored in ctx, or the default logger.
+func FromContext(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(ctxKey{}).(*slog.Logger); ok {
+		return logger
+	}
+	return slog.Default()
+}
+
+// redactingHandler scrubs the message and every string or error attribute
+// before handing the record on.
+type redactingHandler struct {
+	next slog.Handler
+}
+
+func (h *redactingHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+func (h *redactingHandler) Handle(ctx context.Context, r slog.Record) error {
+	out := slog.NewRecord(r.Time, r.Level, Redact(r.Message), r.PC)
+	r.Attrs(func(a slog.Attr) bool {
+		out.AddAttrs(redactAttr(a))
+		return true
+	})
+	return h.next.Handle(ctx, out)
+}
+
+func (h *redactingHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	redacted := make([]slog.Attr, len(attrs))
+	for i, a := range attrs {
+		redacted[i] = redactAttr(a)
+	}
+	return &redactingHandler{next: h.next.WithAttrs(redacted)}
+}
+
+func (h *redactingHandler) WithGroup(name string) slog.Handler {
+	return &redactingHandler{next: h.next.WithGroup(name)}
+}
+
+func redactAttr(a slog.Attr) slog.Attr {
+	if isSecretKey(a.Key) {
+		return slog.String(a.Key, mask)
+	}
+
+	v := a.Value.Resolve()
+	switch v.Kind() {
+	case slog.KindString:
+		return slog.String(a.Key, Redact(v.String()))
+	case slog.KindGroup:
+		group := v.Group()
+		attrs := make([]any, len(group))
+		for i, ga := range group {
+			attrs[i] = redactAttr(ga)
+		}
+		return slog.Group(a.Key, attrs...)
+	case slog.KindAny:
+		switch x := v.Any().(type) {
+		case error:
+			return slog.String(a.Key, Redact(x.Error()))
+		case map[string]interface{}:
+			return slog.Any(a.Key, RedactConfig(x))
+		}
+	}
+	return slog.Attr{Key: a.Key, Value: v}
+}
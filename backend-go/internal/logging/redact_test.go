@@ -0,0 +1,46 @@
+package logging
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestRedact(t *testing.T) {
+	cases := map[string]string{
+		"dial oracle://scott:tiger@db:1521/FREEPDB1 failed":   "dial oracle://scott:***@db:1521/FREEPDB1 failed",
+		"Error 1045: scott:tiger@tcp(db:3306)/rbdb":           "Error 1045: scott:***@tcp(db:3306)/rbdb",
+		"server=db;user id=sa;password=S3cr3t!;database=rbdb": "server=db;user id=sa;password=***;database=rbdb",
+		`{"username":"sa","password":"S3cr3t!"}`:              `{"username":"sa","password":"***"}`,
+		"Authorization: Bearer 1|abcDEF123":                   "Authorization: Bearer ***",
+		"report 42 finished in 3s":                            "report 42 finished in 3s",
+	}
+	for in, want := range cases {
+		if got := Redact(in); got != want {
+			t.Errorf("Redact(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestRedactRegisteredSecret(t *testing.T) {
+	AddSecret("hunter2-long")
+	if got := Redact("ORA-01017: invalid credential hunter2-long"); strings.Contains(got, "hunter2-long") {
+		t.Fatalf("registered secret leaked: %q", got)
+	}
+}
+
+func TestHandlerRedactsAttributes(t *testing.T) {
+	var buf bytes.Buffer
+	logger := setup(&buf, "debug", "json")
+
+	logger.With("dsn", "postgres://app:pw1234@db/rbdb").Error("connect failed",
+		"error", errors.New("pq: password=pw1234 rejected"),
+		"config", map[string]interface{}{"host": "db", "password": "pw1234"},
+		"token", "abc")
+
+	out := buf.String()
+	if strings.Contains(out, "pw1234") || strings.Contains(out, "abc") {
+		t.Fatalf("secret leaked: %s", out)
+	}
+}
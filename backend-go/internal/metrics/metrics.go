@@ -0,0 +1,152 @@
+// Package metrics defines the engine's Prometheus metrics.
+package metrics
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "rbdb"
+
+// Execution stages timed by StageDuration.
+const (
+	StageQuery    = "query"
+	StageGenerate = "generate"
+	StageUpload   = "upload"
+)
+
+// Label value for jobs whose report could not be loaded.
+const Unknown = "unknown"
+
+var (
+	JobsStarted = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "jobs_started_total",
+		Help:      "Executions started, by report type and data source type.",
+	}, []string{"report_type", "source_type"})
+
+	JobsCompleted = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "jobs_completed_total",
+		Help:      "Executions completed successfully, by report type and data source type.",
+	}, []string{"report_type", "source_type"})
+
+	JobsFailed = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "jobs_failed_total",
+		Help:      "Failed execution attempts, including ones that will be retried.",
+	}, []string{"report_type", "source_type"})
+
+	StageDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "stage_duration_seconds",
+		Help:      "Time spent per execution stage. Generate and upload overlap while streaming.",
+		Buckets:   []float64{0.1, 0.5, 1, 5, 15, 30, 60, 120, 300, 600, 1800},
+	}, []string{"stage"})
+
+	DeliveredBytes = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "delivered_bytes_total",
+		Help:      "Bytes of output files uploaded successfully.",
+	})
+
+	ActiveWorkers = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "active_workers",
+		Help:      "Workers currently running an execution.",
+	})
+
+	ExecutionsReaped = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "executions_reaped_total",
+		Help:      "Executions settled by the reaper after exceeding their timeout without a final status, by the status given.",
+	}, []string{"status"})
+
+	OutboxPending = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "outbox_pending",
+		Help:      "Status updates journaled but not yet accepted by the control plane.",
+	})
+
+	CircuitOpen = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "control_plane_circuit_open",
+		Help:      "1 while the circuit breaker holds back control plane calls, else 0.",
+	})
+
+	DBPools = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "db_pools",
+		Help:      "Data source connection pools currently open.",
+	})
+
+	APIErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "control_plane_errors_total",
+		Help:      "Failed control plane API calls, by operation.",
+	}, []string{"operation"})
+)
+
+// ObserveStage records how long a stage took since start.
+func ObserveStage(stage string, start time.Time) {
+	StageDuration.WithLabelValues(stage).Observe(time.Since(start).Seconds())
+}
+
+// Label returns v, or Unknown when it is empty.
+func Label(v string) string {
+	if v == "" {
+		return Unknown
+	}
+	return v
+}
+
+// DepthFunc reports the number of waiting and delayed jobs per lane.
+type DepthFunc func(ctx context.Context) (waiting, delayed map[string]int64, err error)
+
+// queueCollector reads the queue depth from Redis on every scrape, so the
+// value is never stale and nothing polls Redis when nobody scrapes.
+type queueCollector struct {
+	depth DepthFunc
+	desc  *prometheus.Desc
+}
+
+func (c *queueCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+func (c *queueCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	waiting, delayed, err := c.depth(ctx)
+	if err != nil {
+		ch <- prometheus.NewInvalidMetric(c.desc, err)
+		return
+	}
+	for lane, n := range waiting {
+		ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, float64(n), lane, "waiting")
+	}
+	for lane, n := range delayed {
+		ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, float64(n), lane, "delayed")
+	}
+}
+
+// RegisterQueueDepth exposes rbdb_queue_depth{lane,state} using depth.
+func RegisterQueueDepth(depth DepthFunc) {
+	prometheus.MustRegister(&queueCollector{
+		depth: depth,
+		desc: prometheus.NewDesc(prometheus.BuildFQName(namespace, "", "queue_depth"),
+			"Jobs waiting in Redis, by lane and state (waiting or delayed for retry).",
+			[]string{"lane", "state"}, nil),
+	})
+}
+
+// Handler serves the metrics in the Prometheus text format.
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
@@ -25,31 +25,42 @@ type ReportField struct {
 	Format        string `json:"format"`
 }
 
-
+// ReportFilter declares a named report parameter, referenced in the SQL as
+// :variable_name or {{variable_name}}.
+type ReportFilter struct {
+	ID           string  `json:"id"`
+	Label        string  `json:"label"`
+	VariableName string  `json:"variable_name"`
+	FilterType   string  `json:"filter_type"`
+	IsRequired   bool    `json:"is_required"`
+	DefaultValue *string `json:"default_value"`
+	// OrderPosition orders the filters, and with them positional bindings.
+	OrderPosition int `json:"order_position"`
+}
 
 type Report struct {
-	ID                string     `json:"id"`
-	Name              string     `json:"name"`
-	Type              string     `json:"type"` // sql, visual, service
-	SQLDefinition     string     `json:"sql_definition"`
-	Description       string     `json:"description"`
-	ServiceID         string     `json:"service_id"`
-	DataSourceID      string     `json:"data_source_id"`
-	DeliveryMode      string     `json:"delivery_mode"`
-	EmailServerID     string     `json:"email_server_id"`
-	EmailTemplateID   string     `json:"email_template_id"`
-	FtpServerID       string     `json:"ftp_server_id"`
-	DefaultRecipients string     `json:"default_recipients"`
-	Service           Service    `json:"service"`
-	DataSource        DataSource `json:"data_source"`
-	EmailServer       DataSource `json:"email_server"`   // Reusing DataSource struct for simplicity if fields match
-	EmailTemplate     DataSource `json:"email_template"` // Or create specific structs
-	FtpServer         DataSource    `json:"ftp_server"`
-	RetentionPeriod   string        `json:"retention_period"`
-	Fields            []ReportField `json:"fields"`
+	ID                string         `json:"id"`
+	Name              string         `json:"name"`
+	Type              string         `json:"type"` // sql, visual, service
+	SQLDefinition     string         `json:"sql_definition"`
+	Description       string         `json:"description"`
+	ServiceID         string         `json:"service_id"`
+	DataSourceID      string         `json:"data_source_id"`
+	DeliveryMode      string         `json:"delivery_mode"`
+	EmailServerID     string         `json:"email_server_id"`
+	EmailTemplateID   string         `json:"email_template_id"`
+	FtpServerID       string         `json:"ftp_server_id"`
+	DefaultRecipients string         `json:"default_recipients"`
+	Service           Service        `json:"service"`
+	DataSource        DataSource     `json:"data_source"`
+	EmailServer       DataSource     `json:"email_server"`   // Reusing DataSource struct for simplicity if fields match
+	EmailTemplate     DataSource     `json:"email_template"` // Or create specific structs
+	FtpServer         DataSource     `json:"ftp_server"`
+	RetentionPeriod   string         `json:"retention_period"`
+	Fields            []ReportField  `json:"fields"`
+	Filters           []ReportFilter `json:"filters"`
 }
 
-
 type ExecutionUpdate struct {
 	Status      string     `json:"status"`
 	StartedAt   *time.Time `json:"started_at,omitempty"`
@@ -59,23 +70,103 @@ type ExecutionUpdate struct {
 	ErrorLog    string     `json:"error_log,omitempty"`
 	OTP         string     `json:"otp,omitempty"`
 	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+	Attempt     int        `json:"attempt,omitempty"`
+	NextRetryAt *time.Time `json:"next_retry_at,omitempty"`
+
+	Progress *ExecutionProgress `json:"progress,omitempty"`
+}
+
+// Execution stages, in the order a run goes through them.
+const (
+	StageFetchingReport = "fetching_report"
+	StageConnecting     = "connecting"
+	StageQuerying       = "querying"
+	StageGenerating     = "generating"
+	StageUploading      = "uploading"
+)
+
+// ExecutionProgress is a snapshot of a running execution.
+type ExecutionProgress struct {
+	Stage          string    `json:"stage"`
+	Rows           int64     `json:"rows"`
+	Bytes          int64     `json:"bytes"`
+	ElapsedSeconds float64   `json:"elapsed_seconds"`
+	UpdatedAt      time.Time `json:"updated_at"`
 }
 
 type RetryPolicy struct {
-	MaxAttempts      int    `json:"max_attempts"`
-	BackoffStrategy  string `json:"backoff_strategy"`
-	MaxBackoffHours  int    `json:"max_backoff_hours"`
+	MaxAttempts     int    `json:"max_attempts"`
+	BackoffStrategy string `json:"backoff_strategy"`
+	MaxBackoffHours int    `json:"max_backoff_hours"`
+}
+
+// AttemptRecord describes one failed run of a job.
+type AttemptRecord struct {
+	Attempt    int       `json:"attempt"`
+	Error      string    `json:"error"`
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at"`
 }
 
 type Job struct {
-	JobID              string         `json:"job_id"`
-	ExecutionID        string         `json:"execution_id"`
-	ReportID           string         `json:"report_id"`
-	TaskType           string         `json:"task_type"`
-	Priority           string         `json:"priority"`
-	TimeoutSeconds     int            `json:"timeout_seconds"`
-	RetryPolicy        RetryPolicy    `json:"retry_policy"`
-	SQLDefinition      string         `json:"sql_definition"`
-	Bindings           []interface{}  `json:"bindings"`
-	NotificationEmails []string       `json:"notification_emails"`
+	JobID          string          `json:"job_id"`
+	ExecutionID    string          `json:"execution_id"`
+	ReportID       string          `json:"report_id"`
+	TaskType       string          `json:"task_type"`
+	Priority       string          `json:"priority"`
+	TimeoutSeconds int             `json:"timeout_seconds"`
+	RetryPolicy    RetryPolicy     `json:"retry_policy"`
+	Attempt        int             `json:"attempt,omitempty"`
+	AttemptHistory []AttemptRecord `json:"attempt_history,omitempty"`
+	SQLDefinition  string          `json:"sql_definition"`
+	Bindings       []interface{}   `json:"bindings"`
+	// Parameters holds the values of the report's named parameters.
+	Parameters         map[string]interface{} `json:"parameters,omitempty"`
+	NotificationEmails []string               `json:"notification_emails"`
+
+	// TraceContext carries the producer's W3C trace headers, e.g.
+	// {"traceparent": "00-..."}, so the execution joins its trace.
+	TraceContext map[string]string `json:"trace_context,omitempty"`
+
+	// Receipt records where the job was taken from so it can be acked.
+	Receipt Receipt `json:"-"`
+}
+
+// Receipt identifies a job taken from the job source.
+type Receipt struct {
+	Lane    string
+	Payload string
+	// ID is the stream entry ID in stream mode and the claim token in poll
+	// mode; list entries have none.
+	ID string
+}
+
+// EngineRegistration announces an engine and what it can run to the control
+// plane.
+type EngineRegistration struct {
+	EngineID          string    `json:"engine_id"`
+	Version           string    `json:"version"`
+	Hostname          string    `json:"hostname"`
+	WorkerCount       int       `json:"worker_count"`
+	QueueMode         string    `json:"queue_mode"`
+	DBTypes           []string  `json:"db_types"`
+	OutputFormats     []string  `json:"output_formats"`
+	DeliveryTypes     []string  `json:"delivery_types"`
+	HeartbeatInterval int       `json:"heartbeat_interval_seconds"`
+	StartedAt         time.Time `json:"started_at"`
+}
+
+// Engine states reported in heartbeats.
+const (
+	EngineRunning  = "running"
+	EngineDraining = "draining"
+	EngineStopped  = "stopped"
+)
+
+// EngineHeartbeat reports an engine's current state.
+type EngineHeartbeat struct {
+	Status           string   `json:"status"`
+	WorkerCount      int      `json:"worker_count"`
+	RunningCount     int      `json:"running_count"`
+	ActiveExecutions []string `json:"active_executions"`
 }
@@ -0,0 +1,401 @@
+// Package outbox delivers execution status updates to the control plane
+// through a local journal, so updates survive control plane outages and
+// engine restarts.
+package outbox
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"rbdb-backend-go/internal/api_client"
+	"rbdb-backend-go/internal/logging"
+	"rbdb-backend-go/internal/metrics"
+	"rbdb-backend-go/internal/models"
+)
+
+const (
+	// Delivery of an execution's updates backs off from minBackoff, doubling
+	// per failure up to maxBackoff.
+	minBackoff = time.Second
+	maxBackoff = 5 * time.Minute
+	// compactAfter is how many records the journal may grow to before the
+	// settled ones are dropped.
+	compactAfter = 10000
+)
+
+// record is one line of the journal: an update to deliver, or the
+// acknowledgement of an earlier one.
+type record struct {
+	Seq         uint64                  `json:"seq"`
+	Ack         bool                    `json:"ack,omitempty"`
+	ExecutionID string                  `json:"execution_id,omitempty"`
+	Update      *models.ExecutionUpdate `json:"update,omitempty"`
+	At          time.Time               `json:"at,omitempty"`
+}
+
+// backlog is the pending updates of one execution, oldest first. sending
+// is set while its head is being delivered, so Run and Flush never send the
+// same update twice.
+type backlog struct {
+	pending  []record
+	failures int
+	retryAt  time.Time
+	sending  bool
+}
+
+// Outbox journals status updates and delivers them in order per execution,
+// retrying with backoff until the control plane accepts them. Updates it
+// rejects outright are dropped.
+type Outbox struct {
+	client *api_client.Client
+	path   string
+
+	mu      sync.Mutex
+	file    *os.File
+	seq     uint64
+	records int
+	queues  map[string]*backlog
+	wake    chan struct{}
+}
+
+// Open loads the journal at path, creating it if needed. Updates that were
+// pending when the engine stopped are delivered again.
+func Open(path string, client *api_client.Client) (*Outbox, error) {
+	o := &Outbox{
+		client: client,
+		path:   path,
+		queues: make(map[string]*backlog),
+		wake:   make(chan struct{}, 1),
+	}
+	if err := o.load(); err != nil {
+		return nil, err
+	}
+	if err := o.compact(); err != nil {
+		return nil, err
+	}
+	if n := o.Pending(); n > 0 {
+		slog.Info("Outbox has undelivered status updates", "count", n, "path", path)
+	}
+	return o, nil
+}
+
+// load replays the journal. A torn last line, left by a crash mid-write, is
+// skipped.
+func (o *Outbox) load() error {
+	f, err := os.Open(o.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("outbox: %w", err)
+	}
+	defer f.Close()
+
+	acked := make(map[uint64]bool)
+	var updates []record
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var r record
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			slog.Warn("Skipping unreadable outbox record", "path", o.path, "error", err)
+			continue
+		}
+		if r.Seq > o.seq {
+			o.seq = r.Seq
+		}
+		if r.Ack {
+			acked[r.Seq] = true
+		} else if r.Update != nil {
+			updates = append(updates, r)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("outbox: reading %s: %w", o.path, err)
+	}
+
+	for _, r := range updates {
+		if !acked[r.Seq] {
+			o.queue(r.ExecutionID).pending = append(o.queue(r.ExecutionID).pending, r)
+		}
+	}
+	return nil
+}
+
+// compact rewrites the journal with only the pending updates and opens it
+// for appending. Callers hold o.mu, except during Open.
+func (o *Outbox) compact() error {
+	var pending []record
+	for _, b := range o.queues {
+		pending = append(pending, b.pending...)
+	}
+	sort.Slice(pending, func(i, j int) bool { return pending[i].Seq < pending[j].Seq })
+
+	if err := os.MkdirAll(filepath.Dir(o.path), 0o755); err != nil {
+		return fmt.Errorf("outbox: %w", err)
+	}
+	tmp := o.path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("outbox: %w", err)
+	}
+	w := bufio.NewWriter(f)
+	enc := json.NewEncoder(w)
+	for _, r := range pending {
+		if err := enc.Encode(r); err != nil {
+			f.Close()
+			return fmt.Errorf("outbox: %w", err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return fmt.Errorf("outbox: %w", err)
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return fmt.Errorf("outbox: %w", err)
+	}
+	f.Close()
+	if err := os.Rename(tmp, o.path); err != nil {
+		return fmt.Errorf("outbox: %w", err)
+	}
+
+	if o.file != nil {
+		o.file.Close()
+	}
+	o.file, err = os.OpenFile(o.path, os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("outbox: %w", err)
+	}
+	o.records = len(pending)
+	metrics.OutboxPending.Set(float64(len(pending)))
+	return nil
+}
+
+func (o *Outbox) queue(executionID string) *backlog {
+	b, ok := o.queues[executionID]
+	if !ok {
+		b = &backlog{}
+		o.queues[executionID] = b
+	}
+	return b
+}
+
+// append writes r to the journal, syncing it to disk if sync is set.
+// Callers hold o.mu.
+func (o *Outbox) append(r record, sync bool) error {
+	line, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	if _, err := o.file.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	o.records++
+	if sync {
+		return o.file.Sync()
+	}
+	return nil
+}
+
+// Enqueue journals an update for delivery after the updates already pending
+// for the same execution. It returns once the update is on disk.
+func (o *Outbox) Enqueue(executionID string, update models.ExecutionUpdate) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	r := record{Seq: o.seq + 1, ExecutionID: executionID, Update: &update, At: time.Now()}
+	if err := o.append(r, true); err != nil {
+		return fmt.Errorf("outbox: %w", err)
+	}
+	o.seq = r.Seq
+	o.queue(executionID).pending = append(o.queue(executionID).pending, r)
+	metrics.OutboxPending.Inc()
+
+	select {
+	case o.wake <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// Pending returns the number of updates not yet delivered.
+func (o *Outbox) Pending() int {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	n := 0
+	for _, b := range o.queues {
+		n += len(b.pending)
+	}
+	return n
+}
+
+// Run delivers pending updates until ctx is done. Cancel it and wait for it
+// to return before the final Flush.
+func (o *Outbox) Run(ctx context.Context) {
+	for {
+		next := o.deliver(ctx, false)
+		wait := time.Until(next)
+		if next.IsZero() {
+			wait = maxBackoff
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-o.wake:
+		case <-timer.C:
+		}
+		timer.Stop()
+	}
+}
+
+// Flush tries to deliver every pending update once more, ignoring their
+// backoff, until they are all out, one fails or ctx is done. It returns how
+// many are left; they stay in the journal for the next start.
+func (o *Outbox) Flush(ctx context.Context) int {
+	o.deliver(ctx, true)
+	return o.Pending()
+}
+
+// deliver sends the due updates of every execution, oldest first, and
+// returns when the next delivery is due, or zero if nothing is pending.
+// With force it ignores the backoff.
+func (o *Outbox) deliver(ctx context.Context, force bool) time.Time {
+	for {
+		if ctx.Err() != nil {
+			return o.nextDue()
+		}
+		head, ok := o.due(force)
+		if !ok {
+			return o.nextDue()
+		}
+
+		err := o.client.UpdateExecution(ctx, head.ExecutionID, *head.Update)
+		lg := slog.With(logging.KeyExecutionID, head.ExecutionID, "status", head.Update.Status)
+		switch {
+		case err != nil && ctx.Err() != nil:
+			// Stopped mid-call: not the control plane's fault.
+			o.release(head)
+			return o.nextDue()
+		case err == nil:
+			o.settle(head, false)
+		case api_client.IsPermanent(err):
+			lg.Error("Control plane rejected status update, dropping it", "error", err)
+			o.settle(head, false)
+		default:
+			delay := o.settle(head, true)
+			lg.Warn("Status update failed, will retry", "retry_in", delay.String(), "error", err)
+			if force {
+				return o.nextDue()
+			}
+		}
+	}
+}
+
+// due returns the oldest pending update among the executions whose next
+// delivery is due and not already under way, and marks it as being sent.
+func (o *Outbox) due(force bool) (record, bool) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	now := time.Now()
+	var head record
+	var owner *backlog
+	for _, b := range o.queues {
+		if len(b.pending) == 0 || b.sending || (!force && now.Before(b.retryAt)) {
+			continue
+		}
+		if owner == nil || b.pending[0].Seq < head.Seq {
+			head, owner = b.pending[0], b
+		}
+	}
+	if owner == nil {
+		return record{}, false
+	}
+	owner.sending = true
+	return head, true
+}
+
+// release gives back an update taken by due without an outcome.
+func (o *Outbox) release(head record) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if b := o.queues[head.ExecutionID]; b != nil {
+		b.sending = false
+	}
+}
+
+// nextDue returns the earliest time a pending update may be sent, or zero.
+func (o *Outbox) nextDue() time.Time {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	var next time.Time
+	for _, b := range o.queues {
+		if len(b.pending) > 0 && (next.IsZero() || b.retryAt.Before(next)) {
+			next = b.retryAt
+		}
+	}
+	return next
+}
+
+// settle records the outcome of delivering head. A failure backs off the
+// execution and returns the delay; otherwise head is acknowledged in the
+// journal and the execution's next update becomes due.
+func (o *Outbox) settle(head record, failed bool) time.Duration {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	b := o.queues[head.ExecutionID]
+	if b == nil || len(b.pending) == 0 || b.pending[0].Seq != head.Seq {
+		// Settled already; never pop an update that wasn't sent.
+		return 0
+	}
+	b.sending = false
+
+	if failed {
+		b.failures++
+		delay := maxBackoff
+		if b.failures < 20 {
+			delay = min(minBackoff<<(b.failures-1), maxBackoff)
+		}
+		b.retryAt = time.Now().Add(delay)
+		return delay
+	}
+
+	// Acks aren't synced: losing some in a crash only sends the last
+	// updates again, still in order.
+	if err := o.append(record{Seq: head.Seq, Ack: true}, false); err != nil {
+		slog.Warn("Writing outbox acknowledgement failed", "error", err)
+	}
+	b.pending = b.pending[1:]
+	b.failures = 0
+	b.retryAt = time.Time{}
+	if len(b.pending) == 0 {
+		delete(o.queues, head.ExecutionID)
+	}
+	metrics.OutboxPending.Dec()
+
+	if len(o.queues) == 0 || o.records > compactAfter {
+		if err := o.compact(); err != nil {
+			slog.Warn("Compacting outbox failed", "error", err)
+		}
+	}
+	return 0
+}
+
+// Close closes the journal.
+func (o *Outbox) Close() error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.file.Close()
+}
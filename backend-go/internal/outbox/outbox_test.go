@@ -0,0 +1,206 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"rbdb-backend-go/internal/api_client"
+	"rbdb-backend-go/internal/models"
+)
+
+// fakeControlPlane records the updates it accepts, per execution, and
+// answers with status while it is set.
+type fakeControlPlane struct {
+	mu       sync.Mutex
+	status   int
+	received map[string][]string
+}
+
+func (f *fakeControlPlane) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.status != 0 {
+		w.WriteHeader(f.status)
+		return
+	}
+	var u models.ExecutionUpdate
+	json.NewDecoder(r.Body).Decode(&u)
+	id := strings.TrimPrefix(r.URL.Path, "/executions/")
+	f.received[id] = append(f.received[id], u.Status)
+}
+
+func (f *fakeControlPlane) answer(status int) {
+	f.mu.Lock()
+	f.status = status
+	f.mu.Unlock()
+}
+
+func (f *fakeControlPlane) statuses(id string) []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.received[id]
+}
+
+func newTestOutbox(t *testing.T) (*fakeControlPlane, *api_client.Client, string) {
+	t.Helper()
+	cp := &fakeControlPlane{received: make(map[string][]string)}
+	srv := httptest.NewServer(cp)
+	t.Cleanup(srv.Close)
+	client := &api_client.Client{BaseURL: srv.URL, HTTP: srv.Client()}
+	return cp, client, filepath.Join(t.TempDir(), "outbox.jsonl")
+}
+
+func TestOutboxSurvivesRestartAndKeepsOrder(t *testing.T) {
+	cp, client, path := newTestOutbox(t)
+	cp.answer(http.StatusServiceUnavailable)
+
+	o, err := Open(path, client)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	for _, status := range []string{"processing", "completed"} {
+		if err := o.Enqueue("exec-1", models.ExecutionUpdate{Status: status}); err != nil {
+			t.Fatalf("enqueue: %v", err)
+		}
+	}
+	if err := o.Enqueue("exec-2", models.ExecutionUpdate{Status: "failed"}); err != nil {
+		t.Fatalf("enqueue: %v", err)
+	}
+	if left := o.Flush(context.Background()); left != 3 {
+		t.Fatalf("expected all 3 updates pending while the control plane is down, got %d", left)
+	}
+	o.Close()
+
+	// The engine restarts once the control plane is back.
+	cp.answer(0)
+	o, err = Open(path, client)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer o.Close()
+	if left := o.Flush(context.Background()); left != 0 {
+		t.Fatalf("expected everything delivered, %d left", left)
+	}
+
+	if got := strings.Join(cp.statuses("exec-1"), ","); got != "processing,completed" {
+		t.Fatalf("exec-1 received %s", got)
+	}
+	if got := strings.Join(cp.statuses("exec-2"), ","); got != "failed" {
+		t.Fatalf("exec-2 received %s", got)
+	}
+
+	// Delivered updates aren't sent again after another restart.
+	o.Close()
+	o, err = Open(path, client)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	if n := o.Pending(); n != 0 {
+		t.Fatalf("expected an empty outbox, got %d", n)
+	}
+}
+
+func TestOutboxDropsRejectedUpdates(t *testing.T) {
+	cp, client, path := newTestOutbox(t)
+	cp.answer(http.StatusUnprocessableEntity)
+
+	o, err := Open(path, client)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer o.Close()
+	o.Enqueue("exec-1", models.ExecutionUpdate{Status: "bogus"})
+	if left := o.Flush(context.Background()); left != 0 {
+		t.Fatalf("expected the rejected update to be dropped, %d left", left)
+	}
+}
+
+func TestOutboxRunBacksOffAndDelivers(t *testing.T) {
+	cp, client, path := newTestOutbox(t)
+	cp.answer(http.StatusBadGateway)
+
+	o, err := Open(path, client)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer o.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go o.Run(ctx)
+
+	o.Enqueue("exec-1", models.ExecutionUpdate{Status: "completed"})
+	time.Sleep(100 * time.Millisecond)
+	cp.answer(0)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for o.Pending() > 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("update not delivered after the control plane recovered")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if got := cp.statuses("exec-1"); len(got) != 1 {
+		t.Fatalf("expected one delivery, got %v", got)
+	}
+}
+
+func TestOutboxRunAndFlushSendEachUpdateOnce(t *testing.T) {
+	cp, client, path := newTestOutbox(t)
+	box, err := Open(path, client)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer box.Close()
+
+	const executions, updates = 20, 5
+	for i := 0; i < executions; i++ {
+		for j := 0; j < updates; j++ {
+			if err := box.Enqueue(fmt.Sprintf("exec-%d", i), models.ExecutionUpdate{Status: fmt.Sprintf("s%d", j)}); err != nil {
+				t.Fatalf("enqueue: %v", err)
+			}
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	runDone := make(chan struct{})
+	go func() {
+		defer close(runDone)
+		box.Run(ctx)
+	}()
+	var flushes sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		flushes.Add(1)
+		go func() {
+			defer flushes.Done()
+			box.Flush(context.Background())
+		}()
+	}
+	flushes.Wait()
+	// Cancelling Run mid-call would resend that update, which is allowed;
+	// let it finish first.
+	deadline := time.Now().Add(5 * time.Second)
+	for box.Pending() > 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	cancel()
+	<-runDone
+
+	if left := box.Flush(context.Background()); left != 0 {
+		t.Fatalf("expected every update delivered, %d left", left)
+	}
+	for i := 0; i < executions; i++ {
+		got := cp.statuses(fmt.Sprintf("exec-%d", i))
+		if strings.Join(got, ",") != "s0,s1,s2,s3,s4" {
+			t.Fatalf("exec-%d: expected each update once and in order, got %v", i, got)
+		}
+	}
+}
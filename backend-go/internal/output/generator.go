@@ -1,6 +1,7 @@
 package output
 
 import (
+	"context"
 	"database/sql"
 	"encoding/csv"
 	"fmt"
@@ -20,7 +21,16 @@ const (
 	FormatXLSX Format = "xlsx"
 )
 
-func WriteTo(rows *sql.Rows, format Format, w io.Writer, report *models.Report) error {
+// Formats lists every format WriteTo supports.
+var Formats = []Format{FormatCSV, FormatXLSX}
+
+// WriteTo streams rows into w in the given format. It stops with ctx's error
+// as soon as ctx is done. onRow, if not nil, is called after each row written.
+func WriteTo(ctx context.Context, rows *sql.Rows, format Format, w io.Writer, report *models.Report, onRow func()) error {
+	if onRow == nil {
+		onRow = func() {}
+	}
+
 	// Map source column names to aliases (Case-insensitive)
 	aliases := make(map[string]string)
 	formats := make(map[string]string)
@@ -40,16 +50,16 @@ func WriteTo(rows *sql.Rows, format Format, w io.Writer, report *models.Report)
 
 	switch format {
 	case FormatCSV:
-		return writeCSV(rows, w, aliases, formats, visibleFields, hasFields)
+		return writeCSV(ctx, rows, w, aliases, formats, visibleFields, hasFields, onRow)
 	case FormatXLSX:
-		return writeXLSX(rows, w, aliases, formats, visibleFields, hasFields)
+		return writeXLSX(ctx, rows, w, aliases, formats, visibleFields, hasFields, onRow)
 
 	default:
 		return fmt.Errorf("unsupported format: %s", format)
 	}
 }
 
-func writeCSV(rows *sql.Rows, w io.Writer, aliases map[string]string, formats map[string]string, visibleFields map[string]bool, hasFields bool) error {
+func writeCSV(ctx context.Context, rows *sql.Rows, w io.Writer, aliases map[string]string, formats map[string]string, visibleFields map[string]bool, hasFields bool, onRow func()) error {
 	writer := csv.NewWriter(w)
 	defer writer.Flush()
 
@@ -94,6 +104,9 @@ func writeCSV(rows *sql.Rows, w io.Writer, aliases map[string]string, formats ma
 	}
 
 	for rows.Next() {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
 		if err := rows.Scan(valuePtrs...); err != nil {
 			return err
 		}
@@ -103,23 +116,23 @@ func writeCSV(rows *sql.Rows, w io.Writer, aliases map[string]string, formats ma
 			val := values[dbIdx]
 			colNameLower := activeColumnsLower[outputIdx]
 			format := formats[colNameLower]
-            
+
 			if val != nil {
-                // If format exists, try to format
-                if format != "" {
-                    formatted, ok := tryFormatDate(val, format)
-                    if ok {
-                        record[outputIdx] = formatted
-                        continue
-                    }
-                }
-                
+				// If format exists, try to format
+				if format != "" {
+					formatted, ok := tryFormatDate(val, format)
+					if ok {
+						record[outputIdx] = formatted
+						continue
+					}
+				}
+
 				switch v := val.(type) {
 				case []byte:
 					record[outputIdx] = string(v)
 				case time.Time:
-                    record[outputIdx] = v.Format("2006-01-02 15:04:05")
-                default:
+					record[outputIdx] = v.Format("2006-01-02 15:04:05")
+				default:
 					record[outputIdx] = fmt.Sprintf("%v", v)
 				}
 			} else {
@@ -130,13 +143,19 @@ func writeCSV(rows *sql.Rows, w io.Writer, aliases map[string]string, formats ma
 		if err := writer.Write(record); err != nil {
 			return err
 		}
+		onRow()
 	}
 
-	return nil
+	// A cancelled query just ends the row loop; surface it instead of
+	// delivering a truncated file.
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	writer.Flush()
+	return writer.Error()
 }
 
-
-func writeXLSX(rows *sql.Rows, w io.Writer, aliases map[string]string, formats map[string]string, visibleFields map[string]bool, hasFields bool) error {
+func writeXLSX(ctx context.Context, rows *sql.Rows, w io.Writer, aliases map[string]string, formats map[string]string, visibleFields map[string]bool, hasFields bool, onRow func()) error {
 	f := excelize.NewFile()
 	index, _ := f.NewSheet("Sheet1")
 
@@ -184,6 +203,9 @@ func writeXLSX(rows *sql.Rows, w io.Writer, aliases map[string]string, formats m
 
 	rowIdx := 2
 	for rows.Next() {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
 		if err := rows.Scan(valuePtrs...); err != nil {
 			return err
 		}
@@ -193,27 +215,31 @@ func writeXLSX(rows *sql.Rows, w io.Writer, aliases map[string]string, formats m
 			val := values[dbIdx]
 			colNameLower := activeColumnsLower[outputIdx]
 			format := formats[colNameLower]
-            
+
 			if val != nil {
-                if format != "" {
-                    formatted, ok := tryFormatDate(val, format)
-                    if ok {
-                         f.SetCellValue("Sheet1", cell, formatted)
-                         continue
-                    }
-                }
-                
+				if format != "" {
+					formatted, ok := tryFormatDate(val, format)
+					if ok {
+						f.SetCellValue("Sheet1", cell, formatted)
+						continue
+					}
+				}
+
 				switch v := val.(type) {
 				case []byte:
 					f.SetCellValue("Sheet1", cell, string(v))
-                case time.Time:
-                    f.SetCellValue("Sheet1", cell, v.Format("2006-01-02 15:04:05"))
+				case time.Time:
+					f.SetCellValue("Sheet1", cell, v.Format("2006-01-02 15:04:05"))
 				default:
 					f.SetCellValue("Sheet1", cell, v)
 				}
 			}
 		}
 		rowIdx++
+		onRow()
+	}
+	if err := rows.Err(); err != nil {
+		return err
 	}
 
 	f.SetActiveSheet(index)
@@ -223,36 +249,34 @@ func writeXLSX(rows *sql.Rows, w io.Writer, aliases map[string]string, formats m
 
 // Helper to format date
 func tryFormatDate(val interface{}, format string) (string, bool) {
-    // Map common user-friendly formats to Go layout
-    layout := format
-    switch format {
-    case "YYYY-MM-DD":
-        layout = "2006-01-02"
-    case "DD/MM/YYYY":
-        layout = "02/01/2006"
-    case "MM/DD/YYYY":
-        layout = "01/02/2006"
-    case "YYYY-MM-DD HH:mm:ss":
-        layout = "2006-01-02 15:04:05"
-    }
-    
-    switch v := val.(type) {
-    case time.Time:
-        return v.Format(layout), true
-    case []byte:
-        // Try to parse string as time if it looks like a date
-        s := string(v)
-        // Try standard SQL format
-        t, err := time.Parse("2006-01-02 15:04:05", s)
-        if err == nil {
-            return t.Format(layout), true
-        }
-        t, err = time.Parse("2006-01-02", s)
-        if err == nil {
-            return t.Format(layout), true
-        }
-    }
-    return "", false
-}
-
+	// Map common user-friendly formats to Go layout
+	layout := format
+	switch format {
+	case "YYYY-MM-DD":
+		layout = "2006-01-02"
+	case "DD/MM/YYYY":
+		layout = "02/01/2006"
+	case "MM/DD/YYYY":
+		layout = "01/02/2006"
+	case "YYYY-MM-DD HH:mm:ss":
+		layout = "2006-01-02 15:04:05"
+	}
 
+	switch v := val.(type) {
+	case time.Time:
+		return v.Format(layout), true
+	case []byte:
+		// Try to parse string as time if it looks like a date
+		s := string(v)
+		// Try standard SQL format
+		t, err := time.Parse("2006-01-02 15:04:05", s)
+		if err == nil {
+			return t.Format(layout), true
+		}
+		t, err = time.Parse("2006-01-02", s)
+		if err == nil {
+			return t.Format(layout), true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,127 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strings"
+)
+
+// ActionCancel asks engines to stop an execution, queued or running.
+const ActionCancel = "cancel"
+
+// ControlMessage is published by the control plane on the control channel.
+type ControlMessage struct {
+	Action      string `json:"action"`
+	ExecutionID string `json:"execution_id"`
+}
+
+func (q *Queue) controlChannel() string {
+	return q.Name + ":control"
+}
+
+func (q *Queue) cancelKey(executionID string) string {
+	return q.Name + ":cancel:" + executionID
+}
+
+// Subscribe delivers control messages to handle until ctx is done. The
+// subscription reconnects by itself after Redis outages.
+func (q *Queue) Subscribe(ctx context.Context, handle func(ControlMessage)) {
+	sub := q.rdb.Subscribe(ctx, q.controlChannel())
+	defer sub.Close()
+
+	ch := sub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg, ok := <-ch:
+			if !ok {
+				return
+			}
+			var cm ControlMessage
+			if err := json.Unmarshal([]byte(msg.Payload), &cm); err != nil {
+				slog.Warn("Invalid control message", "payload", msg.Payload, "error", err)
+				continue
+			}
+			handle(cm)
+		}
+	}
+}
+
+// IsCancelled reports whether a cancellation was requested for an
+// execution. The control plane sets the key before publishing, so jobs that
+// weren't running when the message went out are still caught.
+func (q *Queue) IsCancelled(ctx context.Context, executionID string) (bool, error) {
+	n, err := q.rdb.Exists(ctx, q.cancelKey(executionID)).Result()
+	return n > 0, err
+}
+
+// RemoveQueued deletes every waiting payload of an execution from the lanes
+// and their delayed sets, returning the removed payloads.
+func (q *Queue) RemoveQueued(ctx context.Context, executionID string) ([]string, error) {
+	removeWaiting := q.removeFromList
+	if q.stream != nil {
+		removeWaiting = q.removeUndelivered
+	}
+
+	var removed []string
+	for _, lane := range Priorities {
+		payloads, err := removeWaiting(ctx, lane, executionID)
+		removed = append(removed, payloads...)
+		if err != nil {
+			return removed, err
+		}
+
+		delayed, err := q.rdb.ZRange(ctx, q.delayedKey(lane), 0, -1).Result()
+		if err != nil {
+			return removed, err
+		}
+		for _, payload := range matching(delayed, executionID) {
+			n, err := q.rdb.ZRem(ctx, q.delayedKey(lane), payload).Result()
+			if err != nil {
+				return removed, err
+			}
+			if n > 0 {
+				removed = append(removed, payload)
+			}
+		}
+	}
+	return removed, nil
+}
+
+func (q *Queue) removeFromList(ctx context.Context, lane, executionID string) ([]string, error) {
+	var removed []string
+	payloads, err := q.rdb.LRange(ctx, q.LaneKey(lane), 0, -1).Result()
+	if err != nil {
+		return nil, err
+	}
+	for _, payload := range matching(payloads, executionID) {
+		n, err := q.rdb.LRem(ctx, q.LaneKey(lane), 1, payload).Result()
+		if err != nil {
+			return removed, err
+		}
+		if n > 0 {
+			removed = append(removed, payload)
+		}
+	}
+	return removed, nil
+}
+
+// matching returns the payloads that belong to executionID.
+func matching(payloads []string, executionID string) []string {
+	var out []string
+	for _, payload := range payloads {
+		// Cheap substring check before paying for a full decode.
+		if !strings.Contains(payload, executionID) {
+			continue
+		}
+		var job struct {
+			ExecutionID string `json:"execution_id"`
+		}
+		if json.Unmarshal([]byte(payload), &job) == nil && job.ExecutionID == executionID {
+			out = append(out, payload)
+		}
+	}
+	return out
+}
@@ -0,0 +1,188 @@
+package queue
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"rbdb-backend-go/internal/models"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// ErrDeadLetterNotFound is returned when a dead-letter ID is unknown.
+var ErrDeadLetterNotFound = errors.New("queue: dead letter not found")
+
+const (
+	ReasonUnparseable      = "unparseable"
+	ReasonRetriesExhausted = "retries_exhausted"
+)
+
+// DeadLetter is a job the engine gave up on, kept for inspection and replay.
+type DeadLetter struct {
+	ID          string                 `json:"id"`
+	Reason      string                 `json:"reason"`
+	Error       string                 `json:"error"`
+	Lane        string                 `json:"lane"`
+	ExecutionID string                 `json:"execution_id,omitempty"`
+	ReportID    string                 `json:"report_id,omitempty"`
+	Payload     string                 `json:"payload"`
+	Attempts    []models.AttemptRecord `json:"attempts,omitempty"`
+	DeadAt      time.Time              `json:"dead_at"`
+}
+
+// replayScript moves an entry out of the dead-letter store and onto its lane
+// only if it is still there, so concurrent replays can't enqueue it twice.
+var replayScript = redis.NewScript(`
+if redis.call('HDEL', KEYS[1], ARGV[1]) == 0 then
+	return 0
+end
+redis.call('ZREM', KEYS[2], ARGV[1])
+redis.call('RPUSH', KEYS[3], ARGV[2])
+return 1
+`)
+
+func (q *Queue) deadKey() string {
+	return q.Name + ":dead"
+}
+
+func (q *Queue) deadIndexKey() string {
+	return q.Name + ":dead:index"
+}
+
+// DeadLetter stores an entry and returns its ID. Entries are kept in a hash
+// keyed by ID and indexed by time in a sorted set.
+func (q *Queue) DeadLetter(ctx context.Context, entry DeadLetter) (string, error) {
+	if entry.ID == "" {
+		id, err := newID()
+		if err != nil {
+			return "", err
+		}
+		entry.ID = id
+	}
+	if entry.DeadAt.IsZero() {
+		entry.DeadAt = time.Now()
+	}
+	entry.Lane = Lane(entry.Lane)
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return "", err
+	}
+
+	_, err = q.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, q.deadKey(), entry.ID, data)
+		pipe.ZAdd(ctx, q.deadIndexKey(), redis.Z{Score: float64(entry.DeadAt.UnixMilli()), Member: entry.ID})
+		return nil
+	})
+	return entry.ID, err
+}
+
+// DeadLetters lists entries, newest first. A limit <= 0 returns everything
+// after offset.
+func (q *Queue) DeadLetters(ctx context.Context, offset, limit int64) ([]DeadLetter, error) {
+	stop := int64(-1)
+	if limit > 0 {
+		stop = offset + limit - 1
+	}
+	ids, err := q.rdb.ZRevRange(ctx, q.deadIndexKey(), offset, stop).Result()
+	if err != nil || len(ids) == 0 {
+		return nil, err
+	}
+
+	values, err := q.rdb.HMGet(ctx, q.deadKey(), ids...).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]DeadLetter, 0, len(values))
+	for _, v := range values {
+		raw, ok := v.(string)
+		if !ok {
+			continue
+		}
+		var entry DeadLetter
+		if err := json.Unmarshal([]byte(raw), &entry); err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// CountDeadLetters returns the number of stored entries.
+func (q *Queue) CountDeadLetters(ctx context.Context) (int64, error) {
+	return q.rdb.ZCard(ctx, q.deadIndexKey()).Result()
+}
+
+// GetDeadLetter loads a single entry.
+func (q *Queue) GetDeadLetter(ctx context.Context, id string) (*DeadLetter, error) {
+	raw, err := q.rdb.HGet(ctx, q.deadKey(), id).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrDeadLetterNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var entry DeadLetter
+	if err := json.Unmarshal([]byte(raw), &entry); err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// ReplayDeadLetter pushes the stored payload back onto its lane and removes
+// the entry.
+func (q *Queue) ReplayDeadLetter(ctx context.Context, id string) error {
+	entry, err := q.GetDeadLetter(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	script, target := replayScript, q.LaneKey(entry.Lane)
+	if q.stream != nil {
+		script, target = replayStreamScript, q.streamKey(entry.Lane)
+	}
+	keys := []string{q.deadKey(), q.deadIndexKey(), target}
+	moved, err := script.Run(ctx, q.rdb, keys, id, entry.Payload).Int()
+	if err != nil {
+		return err
+	}
+	if moved == 0 {
+		return ErrDeadLetterNotFound
+	}
+	if entry.ExecutionID != "" {
+		// Otherwise the replayed payload would be skipped as a duplicate.
+		return q.ClearDone(ctx, entry.ExecutionID)
+	}
+	return nil
+}
+
+// PurgeDeadLetter deletes a single entry.
+func (q *Queue) PurgeDeadLetter(ctx context.Context, id string) error {
+	var deleted *redis.IntCmd
+	_, err := q.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		deleted = pipe.HDel(ctx, q.deadKey(), id)
+		pipe.ZRem(ctx, q.deadIndexKey(), id)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if deleted.Val() == 0 {
+		return ErrDeadLetterNotFound
+	}
+	return nil
+}
+
+func newID() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,51 @@
+package queue
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// promoteScript moves due entries from the delayed set back onto the queue
+// in one step, so two engines never promote the same retry twice.
+var promoteScript = redis.NewScript(`
+local due = redis.call('ZRANGEBYSCORE', KEYS[1], '-inf', ARGV[1], 'LIMIT', 0, ARGV[2])
+for _, payload in ipairs(due) do
+	redis.call('ZREM', KEYS[1], payload)
+	redis.call('RPUSH', KEYS[2], payload)
+end
+return #due
+`)
+
+func (q *Queue) delayedKey(lane string) string {
+	return q.LaneKey(lane) + ":delayed"
+}
+
+// Schedule stores a payload in the lane's delayed set until at.
+func (q *Queue) Schedule(ctx context.Context, lane, payload string, at time.Time) error {
+	return q.rdb.ZAdd(ctx, q.delayedKey(lane), redis.Z{
+		Score:  float64(at.UnixMilli()),
+		Member: payload,
+	}).Err()
+}
+
+// PromoteDue pushes every delayed payload whose time has come back onto its
+// lane and returns how many were moved.
+func (q *Queue) PromoteDue(ctx context.Context) (int, error) {
+	now := strconv.FormatInt(time.Now().UnixMilli(), 10)
+	script, target := promoteScript, q.LaneKey
+	if q.stream != nil {
+		script, target = promoteStreamScript, q.streamKey
+	}
+	total := 0
+	for _, lane := range Priorities {
+		n, err := script.Run(ctx, q.rdb, []string{q.delayedKey(lane), target(lane)}, now, 100).Int()
+		total += n
+		if err != nil {
+			return total, err
+		}
+	}
+	return total, nil
+}
@@ -0,0 +1,139 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+var (
+	// ErrLeaseHeld is returned when another worker already owns an execution.
+	ErrLeaseHeld = errors.New("queue: execution lease held by another worker")
+	// ErrLeaseLost is returned when a lease expired or was taken over.
+	ErrLeaseLost = errors.New("queue: execution lease lost")
+)
+
+const (
+	// LeaseTTL bounds how long a crashed engine blocks an execution.
+	LeaseTTL = 30 * time.Second
+	// doneTTL is how long finished executions are remembered, covering
+	// producer retries that arrive after the first run completed.
+	doneTTL = 24 * time.Hour
+)
+
+var renewScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('PEXPIRE', KEYS[1], ARGV[2])
+end
+return 0
+`)
+
+var releaseScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0
+`)
+
+// Lease is an exclusive, expiring claim on an execution ID.
+type Lease struct {
+	rdb   redis.UniversalClient
+	key   string
+	token string
+	ttl   time.Duration
+}
+
+func (q *Queue) leaseKey(executionID string) string {
+	return q.Name + ":lock:" + executionID
+}
+
+func (q *Queue) doneKey(executionID string) string {
+	return q.Name + ":done:" + executionID
+}
+
+// AcquireLease claims an execution for this engine, failing with
+// ErrLeaseHeld when someone else is already running it.
+func (q *Queue) AcquireLease(ctx context.Context, executionID string) (*Lease, error) {
+	token, err := newID()
+	if err != nil {
+		return nil, err
+	}
+	l := &Lease{
+		rdb:   q.rdb,
+		key:   q.leaseKey(executionID),
+		token: q.EngineID + ":" + token,
+		ttl:   LeaseTTL,
+	}
+
+	ok, err := q.rdb.SetNX(ctx, l.key, l.token, l.ttl).Result()
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrLeaseHeld
+	}
+	return l, nil
+}
+
+// Renew extends the lease, failing with ErrLeaseLost if it is no longer ours.
+func (l *Lease) Renew(ctx context.Context) error {
+	n, err := renewScript.Run(ctx, l.rdb, []string{l.key}, l.token, l.ttl.Milliseconds()).Int()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrLeaseLost
+	}
+	return nil
+}
+
+// Release gives the lease up if it is still ours.
+func (l *Lease) Release(ctx context.Context) error {
+	return releaseScript.Run(ctx, l.rdb, []string{l.key}, l.token).Err()
+}
+
+// KeepAlive renews the lease until ctx is done. onLost is called once if
+// the lease can't be kept, after which the holder must stop working.
+func (l *Lease) KeepAlive(ctx context.Context, onLost func()) {
+	ticker := time.NewTicker(l.ttl / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			err := l.Renew(ctx)
+			if err == nil || ctx.Err() != nil {
+				continue
+			}
+			// Transient errors are retried; once the key has expired the
+			// next renewal reports the loss.
+			if errors.Is(err, ErrLeaseLost) {
+				onLost()
+				return
+			}
+			slog.Warn("Lease renewal failed", "key", l.key, "error", err)
+		}
+	}
+}
+
+// MarkDone remembers that an execution reached a final state, so late
+// duplicates of its payload are skipped.
+func (q *Queue) MarkDone(ctx context.Context, executionID string) error {
+	return q.rdb.Set(ctx, q.doneKey(executionID), time.Now().Unix(), doneTTL).Err()
+}
+
+// IsDone reports whether an execution already reached a final state.
+func (q *Queue) IsDone(ctx context.Context, executionID string) (bool, error) {
+	n, err := q.rdb.Exists(ctx, q.doneKey(executionID)).Result()
+	return n > 0, err
+}
+
+// ClearDone forgets a final state so the execution may run again.
+func (q *Queue) ClearDone(ctx context.Context, executionID string) error {
+	return q.rdb.Del(ctx, q.doneKey(executionID)).Err()
+}
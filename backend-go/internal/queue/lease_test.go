@@ -0,0 +1,54 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestLeaseExclusive(t *testing.T) {
+	ctx := context.Background()
+	a, mr := newTestQueue(t, "engine-a")
+	b := New(a.rdb, "test_queue", "engine-b")
+
+	lease, err := a.AcquireLease(ctx, "exec-1")
+	if err != nil {
+		t.Fatalf("acquire: %v", err)
+	}
+	if _, err := b.AcquireLease(ctx, "exec-1"); !errors.Is(err, ErrLeaseHeld) {
+		t.Fatalf("expected ErrLeaseHeld, got %v", err)
+	}
+
+	// An expired lease can be taken over, after which the old holder is out.
+	mr.FastForward(LeaseTTL)
+	if _, err := b.AcquireLease(ctx, "exec-1"); err != nil {
+		t.Fatalf("acquire after expiry: %v", err)
+	}
+	if err := lease.Renew(ctx); !errors.Is(err, ErrLeaseLost) {
+		t.Fatalf("expected ErrLeaseLost, got %v", err)
+	}
+	if err := lease.Release(ctx); err != nil {
+		t.Fatalf("release: %v", err)
+	}
+	if !mr.Exists("test_queue:lock:exec-1") {
+		t.Fatal("stale release must not drop the new holder's lease")
+	}
+}
+
+func TestDoneMarker(t *testing.T) {
+	ctx := context.Background()
+	q, _ := newTestQueue(t, "engine-a")
+
+	if err := q.MarkDone(ctx, "exec-1"); err != nil {
+		t.Fatalf("mark done: %v", err)
+	}
+	if done, err := q.IsDone(ctx, "exec-1"); err != nil || !done {
+		t.Fatalf("expected exec-1 done, got %v (%v)", done, err)
+	}
+	if err := q.ClearDone(ctx, "exec-1"); err != nil {
+		t.Fatalf("clear done: %v", err)
+	}
+	if done, _ := q.IsDone(ctx, "exec-1"); done {
+		t.Fatal("expected done marker to be cleared")
+	}
+}
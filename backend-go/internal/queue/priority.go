@@ -0,0 +1,90 @@
+package queue
+
+import (
+	"sort"
+	"sync"
+)
+
+const (
+	PriorityHigh   = "high"
+	PriorityMedium = "medium"
+	PriorityLow    = "low"
+)
+
+// Priorities lists the lanes from most to least urgent.
+var Priorities = []string{PriorityHigh, PriorityMedium, PriorityLow}
+
+// DefaultWeights gives high-priority work six turns for every three medium
+// and one low turn, so low-priority jobs still make progress under load.
+var DefaultWeights = map[string]int{
+	PriorityHigh:   6,
+	PriorityMedium: 3,
+	PriorityLow:    1,
+}
+
+// Lane normalizes a job priority to one of the known lanes.
+// Unknown or empty priorities are treated as medium.
+func Lane(priority string) string {
+	switch priority {
+	case PriorityHigh, PriorityLow:
+		return priority
+	default:
+		return PriorityMedium
+	}
+}
+
+// Scheduler picks lanes with smooth weighted round-robin. Credits only
+// accrue while a lane has work, so an idle lane can't hoard turns.
+type Scheduler struct {
+	mu      sync.Mutex
+	weights []int
+	current []int
+}
+
+func NewScheduler(weights map[string]int) *Scheduler {
+	s := &Scheduler{
+		weights: make([]int, len(Priorities)),
+		current: make([]int, len(Priorities)),
+	}
+	for i, p := range Priorities {
+		w := weights[p]
+		if w < 1 {
+			w = 1
+		}
+		s.weights[i] = w
+	}
+	return s
+}
+
+// Pick offers lanes to try in weighted order and returns the first lane for
+// which try succeeds. try must not block.
+func (s *Scheduler) Pick(try func(lane string) bool) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	total := 0
+	for i, w := range s.weights {
+		s.current[i] += w
+		total += w
+	}
+
+	order := make([]int, len(Priorities))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return s.current[order[a]] > s.current[order[b]]
+	})
+
+	for _, i := range order {
+		if try(Priorities[i]) {
+			s.current[i] -= total
+			return Priorities[i], true
+		}
+		// Nothing waiting in this lane: drop its surplus credit.
+		if s.current[i] > 0 {
+			s.current[i] = 0
+		}
+	}
+	return "", false
+}
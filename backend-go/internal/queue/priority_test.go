@@ -0,0 +1,47 @@
+package queue
+
+import "testing"
+
+func TestSchedulerWeightedShare(t *testing.T) {
+	s := NewScheduler(DefaultWeights)
+	counts := map[string]int{}
+	for i := 0; i < 100; i++ {
+		lane, ok := s.Pick(func(string) bool { return true })
+		if !ok {
+			t.Fatal("expected a lane")
+		}
+		counts[lane]++
+	}
+
+	if counts[PriorityHigh] != 60 || counts[PriorityMedium] != 30 || counts[PriorityLow] != 10 {
+		t.Fatalf("unexpected distribution: %v", counts)
+	}
+}
+
+func TestSchedulerSkipsEmptyLanes(t *testing.T) {
+	s := NewScheduler(DefaultWeights)
+	for i := 0; i < 10; i++ {
+		lane, ok := s.Pick(func(lane string) bool { return lane == PriorityLow })
+		if !ok || lane != PriorityLow {
+			t.Fatalf("expected low lane, got %q", lane)
+		}
+	}
+
+	if _, ok := s.Pick(func(string) bool { return false }); ok {
+		t.Fatal("expected no lane when everything is empty")
+	}
+}
+
+func TestLane(t *testing.T) {
+	for priority, expected := range map[string]string{
+		"high":   PriorityHigh,
+		"low":    PriorityLow,
+		"medium": PriorityMedium,
+		"":       PriorityMedium,
+		"urgent": PriorityMedium,
+	} {
+		if got := Lane(priority); got != expected {
+			t.Errorf("Lane(%q) = %q, expected %q", priority, got, expected)
+		}
+	}
+}
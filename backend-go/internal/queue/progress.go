@@ -0,0 +1,29 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+
+	"rbdb-backend-go/internal/models"
+)
+
+// ProgressMessage is published on the progress channel while an execution
+// runs.
+type ProgressMessage struct {
+	ExecutionID string `json:"execution_id"`
+	models.ExecutionProgress
+}
+
+func (q *Queue) progressChannel() string {
+	return q.Name + ":progress"
+}
+
+// PublishProgress broadcasts a progress snapshot. Nobody has to be listening;
+// the message is simply dropped then.
+func (q *Queue) PublishProgress(ctx context.Context, executionID string, progress models.ExecutionProgress) error {
+	data, err := json.Marshal(ProgressMessage{ExecutionID: executionID, ExecutionProgress: progress})
+	if err != nil {
+		return err
+	}
+	return q.rdb.Publish(ctx, q.progressChannel(), data).Err()
+}
@@ -0,0 +1,337 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"rbdb-backend-go/internal/models"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// ErrEmpty is returned by Pop when no job arrived before the timeout.
+var ErrEmpty = errors.New("queue: no job available")
+
+const (
+	heartbeatTTL      = 30 * time.Second
+	heartbeatInterval = 10 * time.Second
+	sweepInterval     = time.Minute
+	promoteInterval   = time.Second
+)
+
+// Queue is an at-least-once consumer on top of Redis lists, one list per
+// priority lane. Popped payloads are moved atomically into a per-engine
+// processing list and only removed from there once acked, so a crash between
+// the pop and the final status update never loses a job.
+type Queue struct {
+	rdb       redis.UniversalClient
+	Name      string
+	EngineID  string
+	scheduler *Scheduler
+	// stream is set in stream mode, see NewStream.
+	stream *streamState
+}
+
+func New(rdb redis.UniversalClient, name, engineID string) *Queue {
+	return &Queue{
+		rdb:       rdb,
+		Name:      name,
+		EngineID:  engineID,
+		scheduler: NewScheduler(DefaultWeights),
+	}
+}
+
+// LaneKey returns the list a priority lane lives in. Medium keeps the base
+// name so producers that don't know about lanes keep working.
+func (q *Queue) LaneKey(lane string) string {
+	if lane == PriorityMedium {
+		return q.Name
+	}
+	return fmt.Sprintf("%s:%s", q.Name, lane)
+}
+
+func (q *Queue) processingKey(lane, engineID string) string {
+	return fmt.Sprintf("%s:processing:%s", q.LaneKey(lane), engineID)
+}
+
+func (q *Queue) aliveKey(engineID string) string {
+	return fmt.Sprintf("%s:engine:%s", q.Name, engineID)
+}
+
+// Pop takes the next payload from the lanes in weighted order and moves it
+// into this engine's processing list for that lane. When every lane is empty
+// it blocks up to timeout on the high lane, so urgent work is picked up
+// immediately.
+func (q *Queue) Pop(ctx context.Context, timeout time.Duration) (models.Receipt, error) {
+	if q.stream != nil {
+		return q.popStream(ctx, timeout)
+	}
+
+	var (
+		payload string
+		err     error
+	)
+	lane, ok := q.scheduler.Pick(func(lane string) bool {
+		if err != nil {
+			return false
+		}
+		payload, err = q.move(ctx, lane)
+		if errors.Is(err, redis.Nil) {
+			err = nil
+			return false
+		}
+		return err == nil
+	})
+	if ok {
+		return models.Receipt{Lane: lane, Payload: payload}, nil
+	}
+	if err != nil {
+		return models.Receipt{}, err
+	}
+
+	payload, err = q.rdb.BLMove(ctx, q.LaneKey(PriorityHigh), q.processingKey(PriorityHigh, q.EngineID), "LEFT", "RIGHT", timeout).Result()
+	if errors.Is(err, redis.Nil) {
+		return models.Receipt{}, ErrEmpty
+	}
+	return models.Receipt{Lane: PriorityHigh, Payload: payload}, err
+}
+
+func (q *Queue) move(ctx context.Context, lane string) (string, error) {
+	return q.rdb.LMove(ctx, q.LaneKey(lane), q.processingKey(lane, q.EngineID), "LEFT", "RIGHT").Result()
+}
+
+// Ack removes a finished payload from the lane's processing list.
+func (q *Queue) Ack(ctx context.Context, r models.Receipt) error {
+	if q.stream != nil {
+		return q.ackStream(ctx, r)
+	}
+	return q.rdb.LRem(ctx, q.processingKey(r.Lane, q.EngineID), 1, r.Payload).Err()
+}
+
+// requeueScript returns an in-flight payload to the head of its lane, but
+// only if this engine still holds it.
+var requeueScript = redis.NewScript(`
+if redis.call('LREM', KEYS[1], 1, ARGV[1]) == 0 then
+	return 0
+end
+redis.call('LPUSH', KEYS[2], ARGV[1])
+return 1
+`)
+
+// Requeue gives an unfinished payload back to the head of its lane. It
+// reports false when the payload was no longer in the processing list.
+func (q *Queue) Requeue(ctx context.Context, r models.Receipt) (bool, error) {
+	if q.stream != nil {
+		return q.requeueStream(ctx, r)
+	}
+	keys := []string{q.processingKey(r.Lane, q.EngineID), q.LaneKey(r.Lane)}
+	n, err := requeueScript.Run(ctx, q.rdb, keys, r.Payload).Int()
+	return n == 1, err
+}
+
+// Ping checks the Redis connection.
+func (q *Queue) Ping(ctx context.Context) error {
+	return q.rdb.Ping(ctx).Err()
+}
+
+// Heartbeat marks this engine as alive so other engines leave its
+// processing list alone.
+func (q *Queue) Heartbeat(ctx context.Context) error {
+	return q.rdb.Set(ctx, q.aliveKey(q.EngineID), time.Now().Unix(), heartbeatTTL).Err()
+}
+
+// Deregister drops the heartbeat so anything left in this engine's
+// processing lists is swept right away instead of after the TTL.
+func (q *Queue) Deregister(ctx context.Context) error {
+	return q.rdb.Del(ctx, q.aliveKey(q.EngineID)).Err()
+}
+
+// Recover runs the startup sweep: jobs left in this engine's own processing
+// lists by a previous run are requeued first, then the lists of engines that
+// stopped sending heartbeats.
+func (q *Queue) Recover(ctx context.Context) (int, error) {
+	if q.stream != nil {
+		return q.recoverStream(ctx)
+	}
+	total := 0
+	for _, lane := range Priorities {
+		n, err := q.requeue(ctx, lane, q.processingKey(lane, q.EngineID))
+		total += n
+		if err != nil {
+			return total, err
+		}
+	}
+	n, err := q.recoverOrphans(ctx)
+	return total + n, err
+}
+
+// Maintain keeps the heartbeat fresh, promotes due retries and periodically
+// sweeps orphaned processing lists until ctx is cancelled.
+func (q *Queue) Maintain(ctx context.Context) {
+	heartbeat := time.NewTicker(heartbeatInterval)
+	defer heartbeat.Stop()
+	sweep := time.NewTicker(sweepInterval)
+	defer sweep.Stop()
+	promote := time.NewTicker(promoteInterval)
+	defer promote.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-heartbeat.C:
+			if err := q.Heartbeat(ctx); err != nil {
+				slog.Error("Queue heartbeat failed", "error", err)
+			}
+			if q.stream != nil {
+				if err := q.touchInFlight(ctx); err != nil {
+					slog.Error("Refreshing in-flight stream entries failed", "error", err)
+				}
+			}
+		case <-promote.C:
+			if _, err := q.PromoteDue(ctx); err != nil {
+				slog.Error("Retry promotion failed", "error", err)
+			}
+		case <-sweep.C:
+			if q.stream != nil {
+				q.maintainStreams(ctx)
+				continue
+			}
+			if n, err := q.recoverOrphans(ctx); err != nil {
+				slog.Error("Orphan sweep failed", "error", err)
+			} else if n > 0 {
+				slog.Info("Requeued orphaned jobs", "count", n)
+			}
+		}
+	}
+}
+
+func (q *Queue) recoverOrphans(ctx context.Context) (int, error) {
+	total := 0
+	for _, lane := range Priorities {
+		n, err := q.recoverLaneOrphans(ctx, lane)
+		total += n
+		if err != nil {
+			return total, err
+		}
+	}
+	return total, nil
+}
+
+func (q *Queue) recoverLaneOrphans(ctx context.Context, lane string) (int, error) {
+	prefix := q.processingKey(lane, "")
+	total := 0
+
+	keys, err := q.scanKeys(ctx, prefix+"*")
+	if err != nil {
+		return 0, err
+	}
+	for _, key := range keys {
+		engineID := key[len(prefix):]
+		if engineID == q.EngineID {
+			continue
+		}
+
+		alive, err := q.rdb.Exists(ctx, q.aliveKey(engineID)).Result()
+		if err != nil {
+			return total, err
+		}
+		if alive > 0 {
+			continue
+		}
+
+		n, err := q.requeue(ctx, lane, key)
+		total += n
+		if err != nil {
+			return total, err
+		}
+		if n > 0 {
+			slog.Info("Requeued jobs from dead engine", "count", n, "lane", lane, "dead_engine_id", engineID)
+		}
+	}
+	return total, nil
+}
+
+// scanKeys returns the keys matching pattern. On a cluster every master is
+// scanned, since SCAN only covers the node it runs on.
+func (q *Queue) scanKeys(ctx context.Context, pattern string) ([]string, error) {
+	cluster, ok := q.rdb.(*redis.ClusterClient)
+	if !ok {
+		return scanNode(ctx, q.rdb, pattern)
+	}
+
+	var (
+		mu   sync.Mutex
+		keys []string
+	)
+	err := cluster.ForEachMaster(ctx, func(ctx context.Context, node *redis.Client) error {
+		found, err := scanNode(ctx, node, pattern)
+		mu.Lock()
+		keys = append(keys, found...)
+		mu.Unlock()
+		return err
+	})
+	return keys, err
+}
+
+func scanNode(ctx context.Context, rdb redis.Cmdable, pattern string) ([]string, error) {
+	var keys []string
+	iter := rdb.Scan(ctx, 0, pattern, 100).Iterator()
+	for iter.Next(ctx) {
+		keys = append(keys, iter.Val())
+	}
+	return keys, iter.Err()
+}
+
+// requeue moves every payload of a processing list back to the head of its
+// lane, keeping the original order.
+func (q *Queue) requeue(ctx context.Context, lane, key string) (int, error) {
+	n := 0
+	for {
+		err := q.rdb.LMove(ctx, key, q.LaneKey(lane), "RIGHT", "LEFT").Err()
+		if errors.Is(err, redis.Nil) {
+			return n, nil
+		}
+		if err != nil {
+			return n, err
+		}
+		n++
+	}
+}
+
+// Depth returns the number of payloads waiting in each lane and in each
+// lane's delayed set.
+func (q *Queue) Depth(ctx context.Context) (waiting, delayed map[string]int64, err error) {
+	lens := make(map[string]*redis.IntCmd, len(Priorities))
+	cards := make(map[string]*redis.IntCmd, len(Priorities))
+	_, err = q.rdb.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, lane := range Priorities {
+			if q.stream == nil {
+				lens[lane] = pipe.LLen(ctx, q.LaneKey(lane))
+			}
+			cards[lane] = pipe.ZCard(ctx, q.delayedKey(lane))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	waiting = make(map[string]int64, len(Priorities))
+	delayed = make(map[string]int64, len(Priorities))
+	for _, lane := range Priorities {
+		if q.stream != nil {
+			if waiting[lane], err = q.streamLag(ctx, lane); err != nil {
+				return nil, nil, err
+			}
+		} else {
+			waiting[lane] = lens[lane].Val()
+		}
+		delayed[lane] = cards[lane].Val()
+	}
+	return waiting, delayed, nil
+}
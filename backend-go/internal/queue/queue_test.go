@@ -0,0 +1,171 @@
+package queue
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func newTestQueue(t *testing.T, engineID string) (*Queue, *miniredis.Miniredis) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { rdb.Close() })
+	return New(rdb, "test_queue", engineID), mr
+}
+
+func TestPopAck(t *testing.T) {
+	ctx := context.Background()
+	q, mr := newTestQueue(t, "engine-a")
+	mr.RPush("test_queue", "job-1")
+
+	r, err := q.Pop(ctx, time.Second)
+	if err != nil {
+		t.Fatalf("pop: %v", err)
+	}
+	if r.Lane != PriorityMedium || r.Payload != "job-1" {
+		t.Fatalf("expected job-1 from medium lane, got %q from %s", r.Payload, r.Lane)
+	}
+
+	inFlight, _ := mr.List("test_queue:processing:engine-a")
+	if len(inFlight) != 1 {
+		t.Fatalf("expected job in processing list, got %v", inFlight)
+	}
+
+	if err := q.Ack(ctx, r); err != nil {
+		t.Fatalf("ack: %v", err)
+	}
+	if mr.Exists("test_queue:processing:engine-a") {
+		t.Fatal("processing list should be empty after ack")
+	}
+}
+
+func TestRecoverRequeuesDeadEngines(t *testing.T) {
+	ctx := context.Background()
+	q, mr := newTestQueue(t, "engine-a")
+
+	mr.RPush("test_queue", "queued")
+	mr.RPush("test_queue:processing:engine-a", "own-1")
+	mr.RPush("test_queue:processing:engine-dead", "dead-1", "dead-2")
+	mr.RPush("test_queue:processing:engine-live", "live-1")
+	mr.RPush("test_queue:high:processing:engine-dead", "dead-high")
+	mr.Set("test_queue:engine:engine-live", "1")
+
+	n, err := q.Recover(ctx)
+	if err != nil {
+		t.Fatalf("recover: %v", err)
+	}
+	if n != 4 {
+		t.Fatalf("expected 4 requeued jobs, got %d", n)
+	}
+
+	items, _ := mr.List("test_queue")
+	if len(items) != 4 || items[len(items)-1] != "queued" {
+		t.Fatalf("unexpected queue contents: %v", items)
+	}
+	if high, _ := mr.List("test_queue:high"); len(high) != 1 || high[0] != "dead-high" {
+		t.Fatalf("high-priority orphan must return to its lane, got %v", high)
+	}
+	if live, _ := mr.List("test_queue:processing:engine-live"); len(live) != 1 {
+		t.Fatalf("live engine list must be untouched, got %v", live)
+	}
+}
+
+func TestPromoteDue(t *testing.T) {
+	ctx := context.Background()
+	q, mr := newTestQueue(t, "engine-a")
+
+	if err := q.Schedule(ctx, PriorityMedium, "due", time.Now().Add(-time.Second)); err != nil {
+		t.Fatalf("schedule: %v", err)
+	}
+	if err := q.Schedule(ctx, PriorityMedium, "later", time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("schedule: %v", err)
+	}
+
+	n, err := q.PromoteDue(ctx)
+	if err != nil {
+		t.Fatalf("promote: %v", err)
+	}
+	if n != 1 {
+		t.Fatalf("expected 1 promoted job, got %d", n)
+	}
+	if items, _ := mr.List("test_queue"); len(items) != 1 || items[0] != "due" {
+		t.Fatalf("unexpected queue contents: %v", items)
+	}
+	if left, _ := mr.ZMembers("test_queue:delayed"); len(left) != 1 || left[0] != "later" {
+		t.Fatalf("unexpected delayed set: %v", left)
+	}
+}
+
+func TestPopPrefersHighLane(t *testing.T) {
+	ctx := context.Background()
+	q, mr := newTestQueue(t, "engine-a")
+	mr.RPush("test_queue", "medium-1")
+	mr.RPush("test_queue:high", "high-1")
+
+	r, err := q.Pop(ctx, time.Second)
+	if err != nil {
+		t.Fatalf("pop: %v", err)
+	}
+	if r.Lane != PriorityHigh || r.Payload != "high-1" {
+		t.Fatalf("expected high-1 first, got %q from %s", r.Payload, r.Lane)
+	}
+	if inFlight, _ := mr.List("test_queue:high:processing:engine-a"); len(inFlight) != 1 {
+		t.Fatalf("expected job in high processing list, got %v", inFlight)
+	}
+}
+
+func TestDeadLetterReplay(t *testing.T) {
+	ctx := context.Background()
+	q, mr := newTestQueue(t, "engine-a")
+
+	id, err := q.DeadLetter(ctx, DeadLetter{
+		Reason:      ReasonRetriesExhausted,
+		Error:       "ORA-12541: no listener",
+		Lane:        PriorityLow,
+		ExecutionID: "exec-1",
+		Payload:     `{"execution_id":"exec-1"}`,
+	})
+	if err != nil {
+		t.Fatalf("dead letter: %v", err)
+	}
+
+	entries, err := q.DeadLetters(ctx, 0, 0)
+	if err != nil || len(entries) != 1 || entries[0].ID != id {
+		t.Fatalf("unexpected entries: %v (%v)", entries, err)
+	}
+
+	if err := q.ReplayDeadLetter(ctx, id); err != nil {
+		t.Fatalf("replay: %v", err)
+	}
+	if items, _ := mr.List("test_queue:low"); len(items) != 1 || items[0] != `{"execution_id":"exec-1"}` {
+		t.Fatalf("payload not replayed onto low lane: %v", items)
+	}
+	if err := q.ReplayDeadLetter(ctx, id); err != ErrDeadLetterNotFound {
+		t.Fatalf("second replay should fail with not found, got %v", err)
+	}
+}
+
+func TestDepth(t *testing.T) {
+	ctx := context.Background()
+	q, mr := newTestQueue(t, "engine-a")
+	mr.RPush("test_queue:high", "h1", "h2")
+	mr.RPush("test_queue", "m1")
+	if err := q.Schedule(ctx, PriorityLow, "l1", time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("schedule: %v", err)
+	}
+
+	waiting, delayed, err := q.Depth(ctx)
+	if err != nil {
+		t.Fatalf("depth: %v", err)
+	}
+	if waiting[PriorityHigh] != 2 || waiting[PriorityMedium] != 1 || waiting[PriorityLow] != 0 {
+		t.Fatalf("unexpected waiting depth: %v", waiting)
+	}
+	if delayed[PriorityLow] != 1 {
+		t.Fatalf("unexpected delayed depth: %v", delayed)
+	}
+}
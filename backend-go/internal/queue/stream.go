@@ -0,0 +1,471 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"rbdb-backend-go/internal/models"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// streamField is the entry field holding the job payload.
+const streamField = "payload"
+
+// maxClaimed bounds how many reclaimed entries wait locally for Pop.
+const maxClaimed = 10
+
+// StreamOptions configures stream mode.
+type StreamOptions struct {
+	// Group is the consumer group shared by every engine.
+	Group string
+	// ClaimIdle is how long an entry may sit unacknowledged before another
+	// engine takes it over. Entries being processed are refreshed on every
+	// heartbeat, so only entries of dead engines go idle.
+	ClaimIdle time.Duration
+	// Retention is how long acknowledged entries are kept for inspection
+	// and replay.
+	Retention time.Duration
+}
+
+type streamState struct {
+	StreamOptions
+
+	mu sync.Mutex
+	// inFlight maps the entry IDs this engine holds to their lane.
+	inFlight map[string]string
+	// claimed holds entries taken over from other consumers, handed out by
+	// Pop before new ones.
+	claimed []models.Receipt
+}
+
+// NewStream returns a queue that reads jobs from one Redis Stream per lane
+// through a consumer group, using the engine ID as consumer name. Delayed
+// retries, leases, cancellation and the dead-letter store work as in list
+// mode.
+func NewStream(rdb redis.UniversalClient, name, engineID string, opts StreamOptions) *Queue {
+	q := New(rdb, name, engineID)
+	q.stream = &streamState{
+		StreamOptions: opts,
+		inFlight:      make(map[string]string),
+	}
+	return q
+}
+
+func (q *Queue) streamKey(lane string) string {
+	return q.LaneKey(lane) + ":stream"
+}
+
+func (s *streamState) track(r models.Receipt) models.Receipt {
+	s.mu.Lock()
+	s.inFlight[r.ID] = r.Lane
+	s.mu.Unlock()
+	return r
+}
+
+func (s *streamState) untrack(id string) {
+	s.mu.Lock()
+	delete(s.inFlight, id)
+	s.mu.Unlock()
+}
+
+func (s *streamState) takeClaimed() (models.Receipt, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.claimed) == 0 {
+		return models.Receipt{}, false
+	}
+	r := s.claimed[0]
+	s.claimed = s.claimed[1:]
+	return r, true
+}
+
+func (s *streamState) addClaimed(rs []models.Receipt) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, r := range rs {
+		s.inFlight[r.ID] = r.Lane
+		s.claimed = append(s.claimed, r)
+	}
+}
+
+func (s *streamState) claimedCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.claimed)
+}
+
+func receiptOf(lane string, msg redis.XMessage) models.Receipt {
+	// Entries without a payload field decode as an empty payload, which the
+	// consumer dead-letters like any other unparseable job.
+	payload, _ := msg.Values[streamField].(string)
+	return models.Receipt{Lane: lane, Payload: payload, ID: msg.ID}
+}
+
+// popStream hands out reclaimed entries first, then reads new entries in
+// weighted lane order, blocking on the high lane when all are empty.
+func (q *Queue) popStream(ctx context.Context, timeout time.Duration) (models.Receipt, error) {
+	if r, ok := q.stream.takeClaimed(); ok {
+		return r, nil
+	}
+
+	var (
+		r   models.Receipt
+		err error
+	)
+	_, ok := q.scheduler.Pick(func(lane string) bool {
+		if err != nil {
+			return false
+		}
+		r, err = q.readGroup(ctx, lane, -1)
+		if errors.Is(err, redis.Nil) {
+			err = nil
+			return false
+		}
+		return err == nil
+	})
+	if ok {
+		return r, nil
+	}
+	if err != nil {
+		return models.Receipt{}, err
+	}
+
+	r, err = q.readGroup(ctx, PriorityHigh, timeout)
+	if errors.Is(err, redis.Nil) {
+		return models.Receipt{}, ErrEmpty
+	}
+	return r, err
+}
+
+// readGroup reads one new entry of a lane. A negative block returns at once.
+func (q *Queue) readGroup(ctx context.Context, lane string, block time.Duration) (models.Receipt, error) {
+	streams, err := q.rdb.XReadGroup(ctx, &redis.XReadGroupArgs{
+		Group:    q.stream.Group,
+		Consumer: q.EngineID,
+		Streams:  []string{q.streamKey(lane), ">"},
+		Count:    1,
+		Block:    block,
+	}).Result()
+	if err != nil {
+		return models.Receipt{}, err
+	}
+	for _, s := range streams {
+		for _, msg := range s.Messages {
+			return q.stream.track(receiptOf(lane, msg)), nil
+		}
+	}
+	return models.Receipt{}, redis.Nil
+}
+
+// ackStreamScript acknowledges an entry only while this engine still owns
+// it. XACK alone would also settle an entry another engine reclaimed.
+var ackStreamScript = redis.NewScript(`
+local pending = redis.call('XPENDING', KEYS[1], ARGV[1], ARGV[2], ARGV[2], 1, ARGV[3])
+if #pending == 0 then
+	return 0
+end
+return redis.call('XACK', KEYS[1], ARGV[1], ARGV[2])
+`)
+
+// requeueStreamScript acknowledges an owned entry and appends its payload
+// as a new entry, so any engine can pick it up right away.
+var requeueStreamScript = redis.NewScript(`
+local pending = redis.call('XPENDING', KEYS[1], ARGV[1], ARGV[2], ARGV[2], 1, ARGV[3])
+if #pending == 0 then
+	return 0
+end
+redis.call('XACK', KEYS[1], ARGV[1], ARGV[2])
+redis.call('XADD', KEYS[1], '*', 'payload', ARGV[4])
+return 1
+`)
+
+// touchScript resets the idle time of entries this engine still owns. A
+// plain XCLAIM would also take back entries another engine reclaimed.
+var touchScript = redis.NewScript(`
+for i = 3, #ARGV do
+	local pending = redis.call('XPENDING', KEYS[1], ARGV[1], ARGV[i], ARGV[i], 1, ARGV[2])
+	if #pending > 0 then
+		redis.call('XCLAIM', KEYS[1], ARGV[1], ARGV[2], 0, ARGV[i], 'JUSTID')
+	end
+end
+return 0
+`)
+
+// promoteStreamScript is promoteScript for stream mode.
+var promoteStreamScript = redis.NewScript(`
+local due = redis.call('ZRANGEBYSCORE', KEYS[1], '-inf', ARGV[1], 'LIMIT', 0, ARGV[2])
+for _, payload in ipairs(due) do
+	redis.call('ZREM', KEYS[1], payload)
+	redis.call('XADD', KEYS[2], '*', 'payload', payload)
+end
+return #due
+`)
+
+// replayStreamScript is replayScript for stream mode.
+var replayStreamScript = redis.NewScript(`
+if redis.call('HDEL', KEYS[1], ARGV[1]) == 0 then
+	return 0
+end
+redis.call('ZREM', KEYS[2], ARGV[1])
+redis.call('XADD', KEYS[3], '*', 'payload', ARGV[2])
+return 1
+`)
+
+func (q *Queue) ackStream(ctx context.Context, r models.Receipt) error {
+	defer q.stream.untrack(r.ID)
+	args := []interface{}{q.stream.Group, r.ID, q.EngineID}
+	return ackStreamScript.Run(ctx, q.rdb, []string{q.streamKey(r.Lane)}, args...).Err()
+}
+
+// requeueStream returns an entry to the end of its stream; streams can't
+// push to the front.
+func (q *Queue) requeueStream(ctx context.Context, r models.Receipt) (bool, error) {
+	defer q.stream.untrack(r.ID)
+	args := []interface{}{q.stream.Group, r.ID, q.EngineID, r.Payload}
+	n, err := requeueStreamScript.Run(ctx, q.rdb, []string{q.streamKey(r.Lane)}, args...).Int()
+	return n == 1, err
+}
+
+// ensureGroups creates the consumer group on every lane stream. A new group
+// starts at the beginning, so entries added before the first engine started
+// are not skipped.
+func (q *Queue) ensureGroups(ctx context.Context) error {
+	for _, lane := range Priorities {
+		err := q.rdb.XGroupCreateMkStream(ctx, q.streamKey(lane), q.stream.Group, "0").Err()
+		if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+			return fmt.Errorf("creating consumer group on %s: %w", q.streamKey(lane), err)
+		}
+	}
+	return nil
+}
+
+// recoverStream creates the consumer groups, then resumes the entries this
+// consumer still held from a previous run and reclaims idle entries of
+// other consumers.
+func (q *Queue) recoverStream(ctx context.Context) (int, error) {
+	if err := q.ensureGroups(ctx); err != nil {
+		return 0, err
+	}
+
+	total := 0
+	for _, lane := range Priorities {
+		// Reading from ID 0 returns this consumer's pending entries.
+		streams, err := q.rdb.XReadGroup(ctx, &redis.XReadGroupArgs{
+			Group:    q.stream.Group,
+			Consumer: q.EngineID,
+			Streams:  []string{q.streamKey(lane), "0"},
+			Block:    -1,
+		}).Result()
+		if err != nil && !errors.Is(err, redis.Nil) {
+			return total, err
+		}
+		var own []models.Receipt
+		for _, s := range streams {
+			for _, msg := range s.Messages {
+				own = append(own, receiptOf(lane, msg))
+			}
+		}
+		q.stream.addClaimed(own)
+		total += len(own)
+	}
+
+	n, err := q.reclaimIdle(ctx)
+	return total + n, err
+}
+
+// reclaimIdle takes over entries that sat unacknowledged for longer than
+// ClaimIdle, which means their engine died.
+func (q *Queue) reclaimIdle(ctx context.Context) (int, error) {
+	total := 0
+	for _, lane := range Priorities {
+		room := maxClaimed - q.stream.claimedCount()
+		if room <= 0 {
+			break
+		}
+		msgs, _, err := q.rdb.XAutoClaim(ctx, &redis.XAutoClaimArgs{
+			Stream:   q.streamKey(lane),
+			Group:    q.stream.Group,
+			Consumer: q.EngineID,
+			MinIdle:  q.stream.ClaimIdle,
+			Start:    "0-0",
+			Count:    int64(room),
+		}).Result()
+		if err != nil {
+			return total, err
+		}
+
+		var claimed []models.Receipt
+		for _, msg := range msgs {
+			claimed = append(claimed, receiptOf(lane, msg))
+			slog.Info("Reclaimed idle stream entry", "lane", lane, "stream_id", msg.ID)
+		}
+		q.stream.addClaimed(claimed)
+		total += len(claimed)
+	}
+	return total, nil
+}
+
+// touchInFlight resets the idle time of the entries this engine holds, so
+// long executions are not reclaimed by other engines.
+func (q *Queue) touchInFlight(ctx context.Context) error {
+	q.stream.mu.Lock()
+	byLane := make(map[string][]string)
+	for id, lane := range q.stream.inFlight {
+		byLane[lane] = append(byLane[lane], id)
+	}
+	q.stream.mu.Unlock()
+
+	for lane, ids := range byLane {
+		args := append([]interface{}{q.stream.Group, q.EngineID}, toArgs(ids)...)
+		if err := touchScript.Run(ctx, q.rdb, []string{q.streamKey(lane)}, args...).Err(); err != nil && !errors.Is(err, redis.Nil) {
+			return err
+		}
+	}
+	return nil
+}
+
+func toArgs(ids []string) []interface{} {
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+	return args
+}
+
+// maintainStreams reclaims idle entries and trims old history.
+func (q *Queue) maintainStreams(ctx context.Context) {
+	if n, err := q.reclaimIdle(ctx); err != nil {
+		slog.Error("Reclaiming idle stream entries failed", "error", err)
+	} else if n > 0 {
+		slog.Info("Reclaimed jobs from dead engines", "count", n)
+	}
+	for _, lane := range Priorities {
+		if err := q.trimStream(ctx, lane); err != nil {
+			slog.Error("Trimming stream failed", "lane", lane, "error", err)
+		}
+	}
+}
+
+// trimStream drops entries older than Retention, but never ones that are
+// pending or not yet delivered to the group.
+func (q *Queue) trimStream(ctx context.Context, lane string) error {
+	if q.stream.Retention <= 0 {
+		return nil
+	}
+	key := q.streamKey(lane)
+	minID := strconv.FormatInt(time.Now().Add(-q.stream.Retention).UnixMilli(), 10) + "-0"
+
+	group, err := q.groupInfo(ctx, lane)
+	if err != nil || group == nil {
+		return ignoreMissing(err)
+	}
+	// Entries after the last delivered one have not been read yet.
+	if compareIDs(group.LastDeliveredID, minID) < 0 {
+		minID = group.LastDeliveredID
+	}
+	if group.Pending > 0 {
+		pending, err := q.rdb.XPending(ctx, key, q.stream.Group).Result()
+		if err != nil {
+			return err
+		}
+		if compareIDs(pending.Lower, minID) < 0 {
+			minID = pending.Lower
+		}
+	}
+	return q.rdb.XTrimMinIDApprox(ctx, key, minID, 0).Err()
+}
+
+func (q *Queue) groupInfo(ctx context.Context, lane string) (*redis.XInfoGroup, error) {
+	groups, err := q.rdb.XInfoGroups(ctx, q.streamKey(lane)).Result()
+	if err != nil {
+		return nil, err
+	}
+	for i := range groups {
+		if groups[i].Name == q.stream.Group {
+			return &groups[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// streamLag returns how many entries of a lane the group has not read yet.
+func (q *Queue) streamLag(ctx context.Context, lane string) (int64, error) {
+	group, err := q.groupInfo(ctx, lane)
+	if err != nil || group == nil {
+		return 0, ignoreMissing(err)
+	}
+	return group.Lag, nil
+}
+
+// removeUndelivered deletes the entries of an execution that the group has
+// not read yet. Entries already handed to an engine are stopped by the
+// cancel flag instead.
+func (q *Queue) removeUndelivered(ctx context.Context, lane, executionID string) ([]string, error) {
+	group, err := q.groupInfo(ctx, lane)
+	if err != nil || group == nil {
+		return nil, ignoreMissing(err)
+	}
+
+	key := q.streamKey(lane)
+	msgs, err := q.rdb.XRange(ctx, key, "("+group.LastDeliveredID, "+").Result()
+	if err != nil {
+		return nil, err
+	}
+	var removed []string
+	for _, msg := range msgs {
+		r := receiptOf(lane, msg)
+		if len(matching([]string{r.Payload}, executionID)) == 0 {
+			continue
+		}
+		n, err := q.rdb.XDel(ctx, key, msg.ID).Result()
+		if err != nil {
+			return removed, err
+		}
+		if n > 0 {
+			removed = append(removed, r.Payload)
+		}
+	}
+	return removed, nil
+}
+
+// ignoreMissing drops the error XINFO returns for a stream that doesn't
+// exist yet, which just means nothing was queued on it.
+func ignoreMissing(err error) error {
+	if err != nil && strings.Contains(err.Error(), "no such key") {
+		return nil
+	}
+	return err
+}
+
+// compareIDs orders two stream IDs of the form ms-seq.
+func compareIDs(a, b string) int {
+	am, as := splitID(a)
+	bm, bs := splitID(b)
+	switch {
+	case am != bm:
+		if am < bm {
+			return -1
+		}
+		return 1
+	case as != bs:
+		if as < bs {
+			return -1
+		}
+		return 1
+	}
+	return 0
+}
+
+func splitID(id string) (ms, seq uint64) {
+	msPart, seqPart, _ := strings.Cut(id, "-")
+	ms, _ = strconv.ParseUint(msPart, 10, 64)
+	seq, _ = strconv.ParseUint(seqPart, 10, 64)
+	return ms, seq
+}
@@ -0,0 +1,160 @@
+package queue
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func newTestStream(t *testing.T, mr *miniredis.Miniredis, engineID string) *Queue {
+	t.Helper()
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { rdb.Close() })
+	q := NewStream(rdb, "test_queue", engineID, StreamOptions{
+		Group:     "engines",
+		ClaimIdle: 50 * time.Millisecond,
+		Retention: time.Hour,
+	})
+	if _, err := q.Recover(context.Background()); err != nil {
+		t.Fatalf("recover: %v", err)
+	}
+	return q
+}
+
+func TestStreamPopAck(t *testing.T) {
+	ctx := context.Background()
+	mr := miniredis.RunT(t)
+	q := newTestStream(t, mr, "engine-a")
+	mr.XAdd("test_queue:stream", "*", []string{"payload", "job-1"})
+
+	r, err := q.Pop(ctx, time.Second)
+	if err != nil {
+		t.Fatalf("pop: %v", err)
+	}
+	if r.Lane != PriorityMedium || r.Payload != "job-1" || r.ID == "" {
+		t.Fatalf("expected job-1 from medium lane, got %+v", r)
+	}
+	if err := q.Ack(ctx, r); err != nil {
+		t.Fatalf("ack: %v", err)
+	}
+
+	pending, err := q.rdb.XPending(ctx, "test_queue:stream", "engines").Result()
+	if err != nil {
+		t.Fatalf("xpending: %v", err)
+	}
+	if pending.Count != 0 {
+		t.Fatalf("expected nothing pending after ack, got %d", pending.Count)
+	}
+	// Acknowledged entries stay in the stream for replay.
+	if n, _ := q.rdb.XLen(ctx, "test_queue:stream").Result(); n != 1 {
+		t.Fatalf("expected the entry to be kept, stream has %d", n)
+	}
+	if _, err := q.Pop(ctx, 10*time.Millisecond); err != ErrEmpty {
+		t.Fatalf("expected empty queue, got %v", err)
+	}
+}
+
+func TestStreamReclaimsIdleEntries(t *testing.T) {
+	ctx := context.Background()
+	mr := miniredis.RunT(t)
+	a := newTestStream(t, mr, "engine-a")
+	b := newTestStream(t, mr, "engine-b")
+	mr.XAdd("test_queue:high:stream", "*", []string{"payload", "job-1"})
+
+	taken, err := a.Pop(ctx, time.Second)
+	if err != nil {
+		t.Fatalf("pop: %v", err)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	n, err := b.reclaimIdle(ctx)
+	if err != nil {
+		t.Fatalf("reclaim: %v", err)
+	}
+	if n != 1 {
+		t.Fatalf("expected one reclaimed entry, got %d", n)
+	}
+	r, err := b.Pop(ctx, time.Second)
+	if err != nil {
+		t.Fatalf("pop reclaimed: %v", err)
+	}
+	if r.ID != taken.ID || r.Lane != PriorityHigh || r.Payload != "job-1" {
+		t.Fatalf("expected the idle entry, got %+v", r)
+	}
+
+	// The old owner lost the entry, so it must not settle it.
+	if ok, err := a.Requeue(ctx, taken); err != nil || ok {
+		t.Fatalf("expected requeue by the old owner to be refused, got %v, %v", ok, err)
+	}
+	if err := b.Ack(ctx, r); err != nil {
+		t.Fatalf("ack: %v", err)
+	}
+	if n, _ := b.rdb.XLen(ctx, "test_queue:high:stream").Result(); n != 1 {
+		t.Fatalf("expected no duplicate entry, stream has %d", n)
+	}
+}
+
+func TestStreamRecoverResumesOwnEntries(t *testing.T) {
+	ctx := context.Background()
+	mr := miniredis.RunT(t)
+	q := newTestStream(t, mr, "engine-a")
+	mr.XAdd("test_queue:low:stream", "*", []string{"payload", "job-1"})
+	if _, err := q.Pop(ctx, time.Second); err != nil {
+		t.Fatalf("pop: %v", err)
+	}
+
+	// A restart under the same engine ID picks the entry up again.
+	restarted := newTestStream(t, mr, "engine-a")
+	r, err := restarted.Pop(ctx, time.Second)
+	if err != nil {
+		t.Fatalf("pop after restart: %v", err)
+	}
+	if r.Lane != PriorityLow || r.Payload != "job-1" {
+		t.Fatalf("expected job-1 from low lane, got %+v", r)
+	}
+}
+
+func TestStreamRequeueAppends(t *testing.T) {
+	ctx := context.Background()
+	mr := miniredis.RunT(t)
+	q := newTestStream(t, mr, "engine-a")
+	mr.XAdd("test_queue:stream", "*", []string{"payload", "job-1"})
+
+	r, err := q.Pop(ctx, time.Second)
+	if err != nil {
+		t.Fatalf("pop: %v", err)
+	}
+	if ok, err := q.Requeue(ctx, r); err != nil || !ok {
+		t.Fatalf("requeue: %v, %v", ok, err)
+	}
+	again, err := q.Pop(ctx, time.Second)
+	if err != nil {
+		t.Fatalf("pop requeued: %v", err)
+	}
+	if again.Payload != "job-1" || again.ID == r.ID {
+		t.Fatalf("expected job-1 as a new entry, got %+v", again)
+	}
+}
+
+func TestStreamPromoteDue(t *testing.T) {
+	ctx := context.Background()
+	mr := miniredis.RunT(t)
+	q := newTestStream(t, mr, "engine-a")
+
+	if err := q.Schedule(ctx, PriorityMedium, "job-1", time.Now().Add(-time.Second)); err != nil {
+		t.Fatalf("schedule: %v", err)
+	}
+	if n, err := q.PromoteDue(ctx); err != nil || n != 1 {
+		t.Fatalf("promote: %d, %v", n, err)
+	}
+	r, err := q.Pop(ctx, time.Second)
+	if err != nil {
+		t.Fatalf("pop: %v", err)
+	}
+	if r.Payload != "job-1" {
+		t.Fatalf("expected promoted job-1, got %+v", r)
+	}
+}
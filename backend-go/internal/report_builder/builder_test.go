@@ -5,7 +5,7 @@ import (
 )
 
 func testConvertPlaceholders(t *testing.T) {
-	builder := NewBuilder()
+	builder := NewBuilder(nil)
 
 	tests := []struct {
 		name     string
@@ -62,5 +62,5 @@ func testConvertPlaceholders(t *testing.T) {
 }
 
 func TestConvertPlaceholders(t *testing.T) {
-    testConvertPlaceholders(t)
+	testConvertPlaceholders(t)
 }
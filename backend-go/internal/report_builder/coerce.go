@@ -0,0 +1,226 @@
+package report_builder
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Filter types a parameter value is coerced to. The control plane's form
+// types are aliases: text and select are strings and number a decimal kept
+// integral when it can be. A date_range holds a start and an end date,
+// which BindNamed binds separately.
+const (
+	filterDate     = "date"
+	filterDatetime = "datetime"
+	filterInteger  = "integer"
+	filterDecimal  = "decimal"
+	filterBoolean  = "boolean"
+	filterString   = "string"
+	filterList     = "list"
+
+	filterText      = "text"
+	filterSelect    = "select"
+	filterNumber    = "number"
+	filterDateRange = "date_range"
+)
+
+const dateLayout = "2006-01-02"
+
+// decimalPattern matches the plain decimal notation databases accept.
+var decimalPattern = regexp.MustCompile(`^[+-]?(\d+\.?\d*|\.\d+)([eE][+-]?\d+)?$`)
+
+// datetimeLayouts are tried in order for datetime values.
+var datetimeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04",
+}
+
+// coerce turns a JSON-decoded value, or a filter's default, into the Go
+// type the drivers expect for filterType: time.Time for dates, int64, bool,
+// string, or []interface{} for lists. Decimals stay strings, so money keeps
+// its exact digits. Filters of an unknown type only get JSON's float64
+// numbers narrowed to int64 where integral.
+func coerce(filterType string, value interface{}) (interface{}, error) {
+	switch strings.ToLower(filterType) {
+	case filterDate:
+		return toTime(value, filterDate, []string{dateLayout})
+	case filterDatetime:
+		return toTime(value, filterDatetime, datetimeLayouts)
+	case filterInteger:
+		return toInteger(value)
+	case filterDecimal:
+		return toDecimal(value)
+	case filterNumber:
+		n, err := toDecimal(value)
+		if err != nil {
+			return nil, err
+		}
+		if f, err := strconv.ParseFloat(n, 64); err == nil {
+			if i, ok := narrow(f).(int64); ok {
+				return i, nil
+			}
+		}
+		return n, nil
+	case filterBoolean:
+		return toBoolean(value)
+	case filterString, filterText, filterSelect:
+		return toString(value)
+	case filterList:
+		items, err := toList(value)
+		if err != nil {
+			return nil, err
+		}
+		for i, item := range items {
+			items[i] = narrow(item)
+		}
+		return items, nil
+	case filterDateRange:
+		items, err := toList(value)
+		if err != nil {
+			return nil, err
+		}
+		if len(items) != 2 {
+			return nil, fmt.Errorf("a date range needs a start and an end, got %d values", len(items))
+		}
+		for i, item := range items {
+			if items[i], err = toTime(item, filterDate, []string{dateLayout}); err != nil {
+				return nil, err
+			}
+		}
+		return items, nil
+	default:
+		return narrow(value), nil
+	}
+}
+
+// normalizeBindings narrows the integral numbers of positional bindings,
+// which carry no filter type, to int64.
+func normalizeBindings(bindings []interface{}) []interface{} {
+	if bindings == nil {
+		return nil
+	}
+	normalized := make([]interface{}, len(bindings))
+	for i, b := range bindings {
+		normalized[i] = narrow(b)
+	}
+	return normalized
+}
+
+// narrow turns integral float64 values into int64 and leaves others alone.
+func narrow(value interface{}) interface{} {
+	if f, ok := value.(float64); ok && f == math.Trunc(f) && math.Abs(f) < 1<<53 {
+		return int64(f)
+	}
+	return value
+}
+
+func toTime(value interface{}, kind string, layouts []string) (interface{}, error) {
+	s, ok := value.(string)
+	if ok {
+		s = strings.TrimSpace(s)
+		for _, layout := range layouts {
+			if t, err := time.Parse(layout, s); err == nil {
+				return t, nil
+			}
+		}
+	}
+	if kind == filterDate {
+		return nil, fmt.Errorf("%v is not a valid date, want YYYY-MM-DD", value)
+	}
+	return nil, fmt.Errorf("%v is not a valid datetime, want YYYY-MM-DD HH:MM[:SS] or RFC 3339", value)
+}
+
+func toInteger(value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case float64:
+		if v == math.Trunc(v) && math.Abs(v) < 1<<53 {
+			return int64(v), nil
+		}
+	case string:
+		if n, err := strconv.ParseInt(strings.TrimSpace(v), 10, 64); err == nil {
+			return n, nil
+		}
+	}
+	return nil, fmt.Errorf("%v is not a valid integer", value)
+}
+
+// toDecimal validates a decimal and returns its digits as they were given.
+// JSON numbers, already float64, are written out in their shortest form.
+func toDecimal(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case string:
+		if s := strings.TrimSpace(v); decimalPattern.MatchString(s) {
+			return s, nil
+		}
+	}
+	return "", fmt.Errorf("%v is not a valid number", value)
+}
+
+func toBoolean(value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case bool:
+		return v, nil
+	case float64:
+		if v == 0 || v == 1 {
+			return v == 1, nil
+		}
+	case string:
+		switch strings.ToLower(strings.TrimSpace(v)) {
+		case "true", "1", "yes", "on":
+			return true, nil
+		case "false", "0", "no", "off":
+			return false, nil
+		}
+	}
+	return nil, fmt.Errorf("%v is not a valid boolean", value)
+}
+
+func toString(value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	}
+	return nil, fmt.Errorf("%v is not a single value", value)
+}
+
+// toList accepts a JSON array of scalars, a comma-separated string, the
+// form filter defaults are stored in, or a single number or boolean.
+func toList(value interface{}) ([]interface{}, error) {
+	switch v := value.(type) {
+	case []interface{}:
+		items := make([]interface{}, len(v))
+		for i, item := range v {
+			switch item.(type) {
+			case string, float64, bool:
+				items[i] = item
+			default:
+				return nil, fmt.Errorf("list item %v is not a single value", item)
+			}
+		}
+		return items, nil
+	case string:
+		var items []interface{}
+		for _, item := range strings.Split(v, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		return items, nil
+	case float64, bool:
+		return []interface{}{v}, nil
+	}
+	return nil, fmt.Errorf("%v is not a list", value)
+}
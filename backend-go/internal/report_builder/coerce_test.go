@@ -0,0 +1,141 @@
+package report_builder
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"rbdb-backend-go/internal/models"
+)
+
+func TestCoerce(t *testing.T) {
+	day := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		filterType string
+		value      interface{}
+		expected   interface{}
+	}{
+		{"date", "2026-03-01", day},
+		{"datetime", "2026-03-01 14:30:00", day.Add(14*time.Hour + 30*time.Minute)},
+		{"datetime", "2026-03-01T14:30:00Z", day.Add(14*time.Hour + 30*time.Minute)},
+		{"integer", float64(42), int64(42)},
+		{"integer", "42", int64(42)},
+		{"decimal", "12.5", "12.5"},
+		{"decimal", " 12345678901234567.89 ", "12345678901234567.89"},
+		{"decimal", float64(3), "3"},
+		{"number", float64(3), int64(3)},
+		{"number", "3.25", "3.25"},
+		{"number", "3.0", int64(3)},
+		{"boolean", "yes", true},
+		{"boolean", float64(0), false},
+		{"string", float64(1200), "1200"},
+		{"text", "EU", "EU"},
+		{"list", []interface{}{float64(1), "b"}, []interface{}{int64(1), "b"}},
+		{"list", "a, b,,c", []interface{}{"a", "b", "c"}},
+		{"date_range", []interface{}{"2026-03-01", "2026-03-02"}, []interface{}{day, day.AddDate(0, 0, 1)}},
+		{"", float64(7), int64(7)},
+	}
+
+	for _, tt := range tests {
+		got, err := coerce(tt.filterType, tt.value)
+		if err != nil {
+			t.Errorf("%s %v: %v", tt.filterType, tt.value, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.expected) {
+			t.Errorf("%s %v: expected %#v, got %#v", tt.filterType, tt.value, tt.expected, got)
+		}
+	}
+}
+
+func TestCoerceRejectsMismatches(t *testing.T) {
+	tests := []struct {
+		filterType string
+		value      interface{}
+	}{
+		{"date", "01/03/2026"},
+		{"date", float64(20260301)},
+		{"datetime", "tomorrow"},
+		{"integer", float64(1.5)},
+		{"integer", "12abc"},
+		{"decimal", "NaN"},
+		{"decimal", "0x1p-2"},
+		{"boolean", "maybe"},
+		{"string", []interface{}{"a"}},
+		{"list", map[string]interface{}{"a": "b"}},
+		{"date_range", []interface{}{"2026-03-01"}},
+	}
+
+	for _, tt := range tests {
+		if got, err := coerce(tt.filterType, tt.value); err == nil {
+			t.Errorf("%s %v: expected an error, got %#v", tt.filterType, tt.value, got)
+		}
+	}
+}
+
+func TestBindNamedCoercesAndExpandsLists(t *testing.T) {
+	defaultIDs := "1,2"
+	filters := []models.ReportFilter{
+		{VariableName: "ids", FilterType: "list", DefaultValue: &defaultIDs},
+		{VariableName: "shops", FilterType: "list"},
+		{VariableName: "min", FilterType: "integer"},
+	}
+
+	query, args, err := BindNamed("SELECT * FROM t WHERE id IN (:ids) AND shop IN (:shops) AND qty >= :min",
+		filters, map[string]interface{}{"shops": []interface{}{}, "min": float64(10)})
+	if err != nil {
+		t.Fatalf("bind: %v", err)
+	}
+	if query != "SELECT * FROM t WHERE id IN (?, ?) AND shop IN (?) AND qty >= ?" {
+		t.Errorf("unexpected query %q", query)
+	}
+	if want := []interface{}{"1", "2", nil, int64(10)}; !reflect.DeepEqual(args, want) {
+		t.Errorf("expected args %#v, got %#v", want, args)
+	}
+
+	_, _, err = BindNamed("SELECT * FROM t WHERE qty >= :min", filters, map[string]interface{}{"min": "ten"})
+	if !errors.Is(err, ErrInvalidParameters) || !strings.Contains(err.Error(), "min: ten is not a valid integer") {
+		t.Fatalf("expected a validation error naming min, got %v", err)
+	}
+}
+
+func TestPositionalBindingsAreNarrowed(t *testing.T) {
+	report := &models.Report{SQLDefinition: "SELECT * FROM t WHERE id = ? AND ratio > ?", DataSource: models.DataSource{Type: "mysql"}}
+	job := models.Job{Bindings: []interface{}{float64(5), 0.5}}
+
+	_, args, err := NewBuilder(nil).prepare(report, job)
+	if err != nil {
+		t.Fatalf("prepare: %v", err)
+	}
+	if want := []interface{}{int64(5), 0.5}; !reflect.DeepEqual(args, want) {
+		t.Errorf("expected %#v, got %#v", want, args)
+	}
+}
+
+func TestPositionalBindingsFollowFilterOrder(t *testing.T) {
+	filters := []models.ReportFilter{
+		{VariableName: "min", FilterType: "integer", OrderPosition: 2},
+		{VariableName: "start", FilterType: "date", OrderPosition: 1, IsRequired: true},
+	}
+	report := &models.Report{SQLDefinition: "SELECT * FROM t WHERE day >= ? AND qty >= ?", DataSource: models.DataSource{Type: "mysql"}, Filters: filters}
+
+	_, args, err := NewBuilder(nil).prepare(report, models.Job{Bindings: []interface{}{"2026-03-01", "10"}})
+	if err != nil {
+		t.Fatalf("prepare: %v", err)
+	}
+	if want := []interface{}{time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC), int64(10)}; !reflect.DeepEqual(args, want) {
+		t.Errorf("expected %#v, got %#v", want, args)
+	}
+
+	_, _, err = NewBuilder(nil).prepare(report, models.Job{Bindings: []interface{}{"01/03/2026", "10"}})
+	if !errors.Is(err, ErrInvalidParameters) || !strings.Contains(err.Error(), "start: 01/03/2026 is not a valid date") {
+		t.Fatalf("expected a validation error naming start, got %v", err)
+	}
+	_, _, err = NewBuilder(nil).prepare(report, models.Job{Bindings: []interface{}{nil, "10"}})
+	if !errors.Is(err, ErrInvalidParameters) || !strings.Contains(err.Error(), "missing required start") {
+		t.Fatalf("expected start to be required, got %v", err)
+	}
+}
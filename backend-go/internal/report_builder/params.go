@@ -0,0 +1,311 @@
+package report_builder
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"rbdb-backend-go/internal/models"
+)
+
+// ErrInvalidParameters is wrapped by errors about the parameter values of
+// an execution. Running it again won't fix them.
+var ErrInvalidParameters = errors.New("invalid report parameters")
+
+// BindNamed rewrites the :name and {{name}} parameters of query into ?
+// placeholders and returns their values in order, a name used twice being
+// bound twice. A value missing from values falls back to the filter's
+// default; required filters without either are rejected, optional ones bind
+// NULL. Values are coerced to their filter's type, and lists take one
+// placeholder per item. A date_range filter is bound through its two ends,
+// name_from and name_to. Queries without named parameters come back
+// unchanged with nil args, so their positional bindings still apply; in a
+// query with ? placeholders only the names of filters and values count as
+// parameters, so Postgres slices like arr[lo:hi] are left alone.
+func BindNamed(query string, filters []models.ReportFilter, values map[string]interface{}) (string, []interface{}, error) {
+	declared := make(map[string]models.ReportFilter, len(filters))
+	for _, f := range filters {
+		declared[f.VariableName] = f
+	}
+
+	names, positional, parts := scanNamed(query, nil)
+	if positional > 0 && len(names) > 0 {
+		names, _, parts = scanNamed(query, func(name string) bool {
+			lookup, _ := rangeEnd(name, declared)
+			_, isFilter := declared[lookup]
+			_, hasValue := values[name]
+			return isFilter || hasValue
+		})
+	}
+	if len(names) == 0 {
+		return query, nil, nil
+	}
+	if positional > 0 {
+		return "", nil, fmt.Errorf("%w: query mixes ? and named parameters", ErrInvalidParameters)
+	}
+
+	bound := make(map[string]interface{}, len(names))
+	seen := make(map[string]bool, len(names))
+	var missing, unknown, invalid []string
+	for _, name := range names {
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		lookup, end := name, -1
+		if _, ok := declared[name]; !ok {
+			lookup, end = rangeEnd(name, declared)
+		}
+		filter, isFilter := declared[lookup]
+		if isFilter && end < 0 && strings.EqualFold(filter.FilterType, filterDateRange) {
+			invalid = append(invalid, fmt.Sprintf("%s: a date range is bound as :%s_from and :%s_to", name, name, name))
+			continue
+		}
+		value, ok := values[lookup]
+		if !ok || value == nil || value == "" {
+			switch {
+			case !isFilter && !ok:
+				unknown = append(unknown, name)
+				continue
+			case isFilter && filter.DefaultValue != nil && *filter.DefaultValue != "":
+				value = *filter.DefaultValue
+			case isFilter && filter.IsRequired:
+				if !slices.Contains(missing, lookup) {
+					missing = append(missing, lookup)
+				}
+				continue
+			default:
+				bound[name] = nil
+				continue
+			}
+		}
+		coerced, err := coerce(filter.FilterType, value)
+		if err != nil {
+			invalid = append(invalid, fmt.Sprintf("%s: %v", lookup, err))
+			continue
+		}
+		if end >= 0 {
+			coerced = coerced.([]interface{})[end]
+		}
+		bound[name] = coerced
+	}
+	if len(missing) > 0 {
+		return "", nil, fmt.Errorf("%w: missing required %s", ErrInvalidParameters, strings.Join(missing, ", "))
+	}
+	if len(unknown) > 0 {
+		return "", nil, fmt.Errorf("%w: no filter or value for %s", ErrInvalidParameters, strings.Join(unknown, ", "))
+	}
+	if len(invalid) > 0 {
+		return "", nil, fmt.Errorf("%w: %s", ErrInvalidParameters, strings.Join(invalid, "; "))
+	}
+
+	var sb strings.Builder
+	args := make([]interface{}, 0, len(names))
+	for i, name := range names {
+		sb.WriteString(parts[i])
+		list, isList := bound[name].([]interface{})
+		if !isList {
+			sb.WriteByte('?')
+			args = append(args, bound[name])
+			continue
+		}
+		// An empty list binds a single NULL, so IN (...) matches nothing.
+		if len(list) == 0 {
+			list = []interface{}{nil}
+		}
+		for j, item := range list {
+			if j > 0 {
+				sb.WriteString(", ")
+			}
+			sb.WriteByte('?')
+			args = append(args, item)
+		}
+	}
+	sb.WriteString(parts[len(names)])
+	return sb.String(), args, nil
+}
+
+// bindPositional coerces the positional bindings of a query as BindNamed
+// does named values. The control plane sends them in the order of the
+// report's filters, so with as many bindings as filters the n-th takes the
+// type, default and requirement of the n-th filter by order_position.
+// Otherwise nothing ties a binding to a filter and only integral numbers
+// are narrowed.
+func bindPositional(bindings []interface{}, filters []models.ReportFilter) ([]interface{}, error) {
+	if len(bindings) == 0 || len(bindings) != len(filters) {
+		return normalizeBindings(bindings), nil
+	}
+	ordered := slices.Clone(filters)
+	slices.SortStableFunc(ordered, func(a, b models.ReportFilter) int {
+		return cmp.Compare(a.OrderPosition, b.OrderPosition)
+	})
+
+	args := make([]interface{}, len(bindings))
+	var missing, invalid []string
+	for i, value := range bindings {
+		filter := ordered[i]
+		if value == nil || value == "" {
+			switch {
+			case filter.DefaultValue != nil && *filter.DefaultValue != "":
+				value = *filter.DefaultValue
+			case filter.IsRequired:
+				missing = append(missing, filter.VariableName)
+				continue
+			default:
+				continue
+			}
+		}
+		coerced, err := coerce(filter.FilterType, value)
+		if err != nil {
+			invalid = append(invalid, fmt.Sprintf("%s: %v", filter.VariableName, err))
+			continue
+		}
+		if _, isList := coerced.([]interface{}); isList {
+			invalid = append(invalid, fmt.Sprintf("%s: a %s is bound by name, not to a single ?", filter.VariableName, filter.FilterType))
+			continue
+		}
+		args[i] = coerced
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("%w: missing required %s", ErrInvalidParameters, strings.Join(missing, ", "))
+	}
+	if len(invalid) > 0 {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidParameters, strings.Join(invalid, "; "))
+	}
+	return args, nil
+}
+
+// rangeEnd maps name_from and name_to to the date_range filter name and
+// the index of that end. Other names come back as they are, with -1.
+func rangeEnd(name string, declared map[string]models.ReportFilter) (string, int) {
+	for end, suffix := range []string{"_from", "_to"} {
+		base, ok := strings.CutSuffix(name, suffix)
+		if !ok {
+			continue
+		}
+		if f, ok := declared[base]; ok && strings.EqualFold(f.FilterType, filterDateRange) {
+			return base, end
+		}
+	}
+	return name, -1
+}
+
+// scanNamed returns the named parameters of query in order of appearance,
+// the number of positional ? and the text around the named ones, one part
+// more than there are names. Quoted strings and identifiers, comments,
+// PostgreSQL :: casts and the :hi of array slices (a colon right after [,
+// a digit or a name) are left alone, as are the names accept refuses when
+// it isn't nil.
+func scanNamed(query string, accept func(name string) bool) ([]string, int, []string) {
+	var names, parts []string
+	positional := 0
+	var sb strings.Builder
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+		switch {
+		case c == '\'' || c == '"':
+			end := closingQuote(query, i)
+			sb.WriteString(query[i:end])
+			i = end - 1
+		case c == '-' && strings.HasPrefix(query[i:], "--"):
+			end := strings.IndexByte(query[i:], '\n')
+			if end < 0 {
+				end = len(query) - i
+			}
+			sb.WriteString(query[i : i+end])
+			i += end - 1
+		case c == '/' && strings.HasPrefix(query[i:], "/*"):
+			end := strings.Index(query[i+2:], "*/")
+			if end < 0 {
+				end = len(query) - i
+			} else {
+				end += 4
+			}
+			sb.WriteString(query[i : i+end])
+			i += end - 1
+		case c == '?':
+			positional++
+			sb.WriteByte(c)
+		case c == ':' && i+1 < len(query) && query[i+1] == ':':
+			sb.WriteString("::")
+			i++
+		case c == ':' && i+1 < len(query) && isNameStart(query[i+1]) && (i == 0 || !inSlice(query[i-1])):
+			end := i + 1
+			for end < len(query) && isNamePart(query[end]) {
+				end++
+			}
+			if accept != nil && !accept(query[i+1:end]) {
+				sb.WriteString(query[i:end])
+				i = end - 1
+				continue
+			}
+			names = append(names, query[i+1:end])
+			parts = append(parts, sb.String())
+			sb.Reset()
+			i = end - 1
+		case c == '{' && strings.HasPrefix(query[i:], "{{"):
+			end := strings.Index(query[i+2:], "}}")
+			if end < 0 {
+				sb.WriteByte(c)
+				continue
+			}
+			name := strings.TrimSpace(query[i+2 : i+2+end])
+			if !isName(name) || (accept != nil && !accept(name)) {
+				sb.WriteByte(c)
+				continue
+			}
+			names = append(names, name)
+			parts = append(parts, sb.String())
+			sb.Reset()
+			i += end + 3
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	return names, positional, append(parts, sb.String())
+}
+
+// closingQuote returns the index just past the quote closing the one at
+// start, treating a doubled quote as an escaped one.
+func closingQuote(query string, start int) int {
+	quote := query[start]
+	for i := start + 1; i < len(query); i++ {
+		if query[i] != quote {
+			continue
+		}
+		if i+1 < len(query) && query[i+1] == quote {
+			i++
+			continue
+		}
+		return i + 1
+	}
+	return len(query)
+}
+
+// inSlice reports whether a colon after c separates the bounds of an array
+// slice rather than starting a parameter.
+func inSlice(c byte) bool {
+	return c == '[' || isNamePart(c)
+}
+
+func isNameStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isNamePart(c byte) bool {
+	return isNameStart(c) || (c >= '0' && c <= '9')
+}
+
+func isName(s string) bool {
+	if s == "" || !isNameStart(s[0]) {
+		return false
+	}
+	for i := 1; i < len(s); i++ {
+		if !isNamePart(s[i]) {
+			return false
+		}
+	}
+	return true
+}
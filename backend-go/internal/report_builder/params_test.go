@@ -0,0 +1,209 @@
+package report_builder
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"rbdb-backend-go/internal/models"
+)
+
+func TestBindNamed(t *testing.T) {
+	region := "EU"
+	day := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	filters := []models.ReportFilter{
+		{VariableName: "start", FilterType: "date", IsRequired: true},
+		{VariableName: "region", FilterType: "text", DefaultValue: &region},
+		{VariableName: "status", FilterType: "select"},
+	}
+
+	tests := []struct {
+		name      string
+		query     string
+		values    map[string]interface{}
+		wantQuery string
+		wantArgs  []interface{}
+		wantErr   string
+	}{
+		{
+			name:      "colon and braces",
+			query:     "SELECT * FROM sales WHERE day >= :start AND region = {{ region }}",
+			values:    map[string]interface{}{"start": "2026-01-01", "region": "US"},
+			wantQuery: "SELECT * FROM sales WHERE day >= ? AND region = ?",
+			wantArgs:  []interface{}{day, "US"},
+		},
+		{
+			name:      "defaults, NULL for optional and repeats",
+			query:     "SELECT * FROM sales WHERE day >= :start AND region = :region AND (:status IS NULL OR status = :status)",
+			values:    map[string]interface{}{"start": "2026-01-01"},
+			wantQuery: "SELECT * FROM sales WHERE day >= ? AND region = ? AND (? IS NULL OR status = ?)",
+			wantArgs:  []interface{}{day, "EU", nil, nil},
+		},
+		{
+			name:      "strings, comments and casts are left alone",
+			query:     "SELECT ':x', \"a:b\", day::date -- :y\nFROM t /* {{z}} */ WHERE day >= :start",
+			values:    map[string]interface{}{"start": "2026-01-01"},
+			wantQuery: "SELECT ':x', \"a:b\", day::date -- :y\nFROM t /* {{z}} */ WHERE day >= ?",
+			wantArgs:  []interface{}{day},
+		},
+		{
+			name:      "positional queries are untouched",
+			query:     "SELECT * FROM sales WHERE id = ?",
+			wantQuery: "SELECT * FROM sales WHERE id = ?",
+		},
+		{
+			name:      "positional queries with slices and variables are untouched",
+			query:     "SELECT arr[1:n], arr[lo:hi], arr[:n], @total := 0, arr[i : n] FROM t WHERE id = ?",
+			wantQuery: "SELECT arr[1:n], arr[lo:hi], arr[:n], @total := 0, arr[i : n] FROM t WHERE id = ?",
+		},
+		{
+			name:      "slices next to named parameters",
+			query:     "SELECT arr[lo:hi] FROM t WHERE day >= :start",
+			values:    map[string]interface{}{"start": "2026-01-01"},
+			wantQuery: "SELECT arr[lo:hi] FROM t WHERE day >= ?",
+			wantArgs:  []interface{}{day},
+		},
+		{
+			name:    "missing required",
+			query:   "SELECT * FROM sales WHERE day >= :start OR day < :start",
+			wantErr: "missing required start",
+		},
+		{
+			name:    "undeclared",
+			query:   "SELECT * FROM sales WHERE day >= :start AND shop = :shop",
+			values:  map[string]interface{}{"start": "2026-01-01"},
+			wantErr: "no filter or value for shop",
+		},
+		{
+			name:    "mixed",
+			query:   "SELECT * FROM sales WHERE day >= :start AND id = ?",
+			values:  map[string]interface{}{"start": "2026-01-01"},
+			wantErr: "mixes",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, args, err := BindNamed(tt.query, filters, tt.values)
+			if tt.wantErr != "" {
+				if !errors.Is(err, ErrInvalidParameters) || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("expected an error about %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("bind: %v", err)
+			}
+			if query != tt.wantQuery {
+				t.Errorf("expected %q, got %q", tt.wantQuery, query)
+			}
+			if !reflect.DeepEqual(args, tt.wantArgs) {
+				t.Errorf("expected args %v, got %v", tt.wantArgs, args)
+			}
+		})
+	}
+}
+
+func TestPrepareConvertsNamedParameters(t *testing.T) {
+	report := &models.Report{
+		SQLDefinition: "SELECT * FROM sales WHERE day >= :start AND region = :region",
+		DataSource:    models.DataSource{Type: "oracle"},
+		Filters:       []models.ReportFilter{{VariableName: "start"}, {VariableName: "region"}},
+	}
+	job := models.Job{Parameters: map[string]interface{}{"start": "2026-01-01", "region": "EU"}}
+
+	query, args, err := NewBuilder(nil).prepare(report, job)
+	if err != nil {
+		t.Fatalf("prepare: %v", err)
+	}
+	if query != "SELECT * FROM sales WHERE day >= :p1 AND region = :p2" || len(args) != 2 {
+		t.Fatalf("unexpected %q with %v", query, args)
+	}
+}
+
+// recordingDriver accepts any query, returns no rows and keeps the last
+// query and arguments it saw.
+type recordingDriver struct {
+	mu    sync.Mutex
+	query string
+	args  []driver.NamedValue
+}
+
+func (d *recordingDriver) Open(string) (driver.Conn, error) { return recordingConn{d}, nil }
+
+func (d *recordingDriver) last() (string, []driver.NamedValue) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.query, d.args
+}
+
+type recordingConn struct{ d *recordingDriver }
+
+func (c recordingConn) Prepare(string) (driver.Stmt, error) { return nil, errors.New("not supported") }
+func (c recordingConn) Close() error                        { return nil }
+func (c recordingConn) Begin() (driver.Tx, error)           { return nil, errors.New("not supported") }
+
+func (c recordingConn) QueryContext(_ context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	c.d.mu.Lock()
+	defer c.d.mu.Unlock()
+	c.d.query, c.d.args = query, args
+	return noRows{}, nil
+}
+
+type noRows struct{}
+
+func (noRows) Columns() []string         { return []string{"n"} }
+func (noRows) Close() error              { return nil }
+func (noRows) Next([]driver.Value) error { return io.EOF }
+
+var recorder = &recordingDriver{}
+
+func init() {
+	sql.Register("rbdb-recording", recorder)
+}
+
+func TestBuilderRunsDateRangeBetween(t *testing.T) {
+	ds := models.DataSource{ID: "ds-1", Type: "postgres", ConnectionConfig: map[string]interface{}{"host": "db"}}
+	db, err := sql.Open("rbdb-recording", "")
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	_, dsn, _ := dataSourceName(ds)
+	pools := NewPools(PoolSettings{MaxOpen: 1})
+	pools.pools[ds.ID] = &pool{db: db, fingerprint: fingerprint(ds.Type, dsn)}
+	defer pools.Close()
+
+	report := &models.Report{
+		SQLDefinition: "SELECT * FROM sales WHERE day BETWEEN :period_from AND :period_to",
+		DataSource:    ds,
+		Filters:       []models.ReportFilter{{VariableName: "period", FilterType: "date_range", IsRequired: true}},
+	}
+	job := models.Job{Parameters: map[string]interface{}{"period": []interface{}{"2026-03-01", "2026-03-31"}}}
+
+	rows, err := NewBuilder(pools).ExecuteAndReturnRows(context.Background(), report, job)
+	if err != nil {
+		t.Fatalf("execute: %v", err)
+	}
+	rows.Close()
+
+	query, args := recorder.last()
+	if query != "SELECT * FROM sales WHERE day BETWEEN $1 AND $2" {
+		t.Errorf("unexpected query %q", query)
+	}
+	from := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	if len(args) != 2 || args[0].Value != from || args[1].Value != from.AddDate(0, 0, 30) {
+		t.Errorf("expected the range's start and end, got %+v", args)
+	}
+
+	report.SQLDefinition = "SELECT * FROM sales WHERE day BETWEEN :period"
+	if _, err := NewBuilder(pools).ExecuteAndReturnRows(context.Background(), report, job); !errors.Is(err, ErrInvalidParameters) {
+		t.Fatalf("expected the bare range to be rejected, got %v", err)
+	}
+}
@@ -0,0 +1,136 @@
+package report_builder
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"sync"
+	"time"
+
+	"rbdb-backend-go/internal/logging"
+	"rbdb-backend-go/internal/metrics"
+	"rbdb-backend-go/internal/models"
+)
+
+// PoolSettings sizes each data source's connection pool, as the
+// database/sql setters do: a MaxOpen of zero is unlimited, a MaxIdle of
+// zero keeps no idle connections and zero durations never expire them.
+type PoolSettings struct {
+	MaxOpen     int
+	MaxIdle     int
+	MaxLifetime time.Duration
+	MaxIdleTime time.Duration
+}
+
+// Pools shares one *sql.DB per data source across executions. A pool is
+// keyed by the data source ID and remembers a fingerprint of the connection
+// settings it was opened with; when the control plane hands out different
+// settings the old pool is evicted and a new one opened.
+type Pools struct {
+	mu       sync.Mutex
+	settings PoolSettings
+	pools    map[string]*pool
+	closed   bool
+}
+
+type pool struct {
+	db          *sql.DB
+	fingerprint string
+}
+
+func NewPools(settings PoolSettings) *Pools {
+	return &Pools{settings: settings, pools: make(map[string]*pool)}
+}
+
+// Get returns the pool for ds, opening it on first use or when its
+// connection settings changed.
+func (p *Pools) Get(ctx context.Context, ds models.DataSource) (*sql.DB, error) {
+	driver, dsn, err := dataSourceName(ds)
+	if err != nil {
+		return nil, err
+	}
+	fp := fingerprint(ds.Type, dsn)
+	key := ds.ID
+	if key == "" {
+		key = fp
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return nil, sql.ErrConnDone
+	}
+	cached, ok := p.pools[key]
+	if ok && cached.fingerprint == fp {
+		return cached.db, nil
+	}
+
+	cfg := ds.ConnectionConfig
+	// Driver errors may quote the password; make sure logs never show it.
+	if password, ok := cfg["password"].(string); ok {
+		logging.AddSecret(password)
+	}
+	lg := logging.FromContext(ctx)
+	if ok {
+		lg.Info("Data source connection settings changed, replacing its pool")
+		p.evict(key, cached)
+	}
+	lg.Info("Opening data source pool",
+		"type", ds.Type, "host", cfg["host"], "username", cfg["username"])
+
+	db, err := sql.Open(driver, dsn)
+	if err != nil {
+		return nil, err
+	}
+	p.settings.apply(db)
+	p.pools[key] = &pool{db: db, fingerprint: fp}
+	metrics.DBPools.Set(float64(len(p.pools)))
+	return db, nil
+}
+
+// Configure resizes every open pool and those opened later.
+func (p *Pools) Configure(settings PoolSettings) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.settings = settings
+	for _, cached := range p.pools {
+		settings.apply(cached.db)
+	}
+}
+
+// Close closes every pool, waiting for queries still running on them.
+func (p *Pools) Close() {
+	p.mu.Lock()
+	pools := p.pools
+	p.pools = make(map[string]*pool)
+	p.closed = true
+	metrics.DBPools.Set(0)
+	p.mu.Unlock()
+
+	for _, cached := range pools {
+		cached.db.Close()
+	}
+}
+
+// evict drops the pool under key. Executions still reading from it keep
+// their connections; the pool closes once they are done. Callers hold p.mu.
+func (p *Pools) evict(key string, cached *pool) {
+	delete(p.pools, key)
+	metrics.DBPools.Set(float64(len(p.pools)))
+	go cached.db.Close()
+}
+
+func (s PoolSettings) apply(db *sql.DB) {
+	db.SetMaxOpenConns(s.MaxOpen)
+	db.SetMaxIdleConns(s.MaxIdle)
+	db.SetConnMaxLifetime(s.MaxLifetime)
+	db.SetConnMaxIdleTime(s.MaxIdleTime)
+}
+
+// fingerprint identifies a data source's connection settings without
+// keeping its password around in the clear.
+func fingerprint(dbType, dsn string) string {
+	sum := sha256.Sum256([]byte(dbType + "\x00" + dsn))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,52 @@
+package report_builder
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"rbdb-backend-go/internal/models"
+)
+
+func TestPoolsShareAndReplace(t *testing.T) {
+	pools := NewPools(PoolSettings{MaxOpen: 4, MaxIdle: 1})
+	ctx := context.Background()
+	ds := models.DataSource{ID: "ds-1", Type: "postgres", ConnectionConfig: map[string]interface{}{
+		"host": "db", "port": 5432, "username": "rbdb", "password": "old", "database": "sales",
+	}}
+
+	first, err := pools.Get(ctx, ds)
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	again, _ := pools.Get(ctx, ds)
+	if again != first {
+		t.Fatal("expected executions on the same data source to share its pool")
+	}
+	if got := first.Stats().MaxOpenConnections; got != 4 {
+		t.Fatalf("expected max 4 open connections, got %d", got)
+	}
+
+	ds.ConnectionConfig = map[string]interface{}{
+		"host": "db", "port": 5432, "username": "rbdb", "password": "new", "database": "sales",
+	}
+	replaced, _ := pools.Get(ctx, ds)
+	if replaced == first {
+		t.Fatal("expected changed connection settings to open a new pool")
+	}
+	// A cancelled ping never dials, but still reports a closed pool.
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	deadline := time.Now().Add(time.Second)
+	for first.PingContext(cancelled).Error() != "sql: database is closed" {
+		if time.Now().After(deadline) {
+			t.Fatal("expected the replaced pool to be closed")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	pools.Close()
+	if _, err := pools.Get(ctx, ds); err == nil {
+		t.Fatal("expected no pools after Close")
+	}
+}
@@ -0,0 +1,47 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestExtractInjectRoundTrip(t *testing.T) {
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	otel.SetTracerProvider(sdktrace.NewTracerProvider())
+
+	const parent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	ctx := Extract(context.Background(), map[string]string{"traceparent": parent})
+
+	ctx, span := Start(ctx, "execution")
+	defer span.End()
+	if got := span.SpanContext().TraceID().String(); got != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Fatalf("span trace ID = %s, want the payload's", got)
+	}
+
+	header := http.Header{}
+	Inject(ctx, header)
+	sc := trace.SpanContextFromContext(ctx)
+	want := "00-" + sc.TraceID().String() + "-" + sc.SpanID().String() + "-01"
+	if got := header.Get("traceparent"); got != want {
+		t.Fatalf("traceparent = %q, want %q", got, want)
+	}
+}
+
+func TestExtractWithoutCarrier(t *testing.T) {
+	ctx := context.Background()
+	if Extract(ctx, nil) != ctx {
+		t.Fatal("Extract without a carrier should return ctx unchanged")
+	}
+}
+
+func TestSetupRejectsUnknownExporter(t *testing.T) {
+	if _, err := Setup(context.Background(), "zipkin", "", "engine-1"); err == nil {
+		t.Fatal("Setup accepted an unknown exporter")
+	}
+}
@@ -0,0 +1,30 @@
+<?php
+
+namespace App\Console\Commands;
+
+use App\Models\Engine;
+use App\Models\Execution;
+use Illuminate\Console\Command;
+
+class CheckEngineHeartbeatsCommand extends Command
+{
+    protected $signature = 'engines:check-heartbeats';
+    protected $description = 'Mark engines that missed their heartbeats as lost and flag the executions they were running';
+
+    public function handle()
+    {
+        $engines = Engine::whereIn('status', ['running', 'draining'])->get()
+            ->filter(fn (Engine $engine) => $engine->isOverdue());
+
+        foreach ($engines as $engine) {
+            $engine->update(['status' => 'lost']);
+
+            $flagged = Execution::where('engine_id', $engine->id)
+                ->where('status', 'processing')
+                ->whereNull('engine_lost_at')
+                ->update(['engine_lost_at' => now()]);
+
+            $this->warn("Engine {$engine->id} missed its heartbeats; flagged {$flagged} executions");
+        }
+    }
+}
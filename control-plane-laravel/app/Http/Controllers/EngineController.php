@@ -0,0 +1,98 @@
+<?php
+
+namespace App\Http\Controllers;
+
+use App\Models\Engine;
+use App\Models\Execution;
+use Illuminate\Http\JsonResponse;
+use Illuminate\Http\Request;
+
+class EngineController extends BaseController
+{
+    /**
+     * List the engines with their last reported state.
+     */
+    public function index(): JsonResponse
+    {
+        $this->authorize('viewAny', Engine::class);
+
+        $engines = Engine::orderBy('id')->get()->map(fn (Engine $engine) => array_merge(
+            $engine->toArray(),
+            ['overdue' => $engine->isOverdue()]
+        ));
+
+        return $this->sendResponse($engines, 'Engines retrieved successfully.');
+    }
+
+    /**
+     * Register an engine at startup, replacing what an earlier run of the
+     * same engine reported.
+     */
+    public function register(Request $request): JsonResponse
+    {
+        $this->authorize('report', Engine::class);
+        $validated = $request->validate([
+            'engine_id' => 'required|string|max:255',
+            'version' => 'nullable|string|max:100',
+            'hostname' => 'nullable|string|max:255',
+            'worker_count' => 'required|integer|min:0',
+            'queue_mode' => 'nullable|string|max:20',
+            'db_types' => 'nullable|array',
+            'output_formats' => 'nullable|array',
+            'delivery_types' => 'nullable|array',
+            'heartbeat_interval_seconds' => 'required|integer|min:1',
+            'started_at' => 'nullable|date',
+        ]);
+
+        $engine = Engine::updateOrCreate(['id' => $validated['engine_id']], array_merge(
+            collect($validated)->except('engine_id')->all(),
+            [
+                'status' => 'running',
+                'running_count' => 0,
+                'active_executions' => [],
+                'last_heartbeat_at' => now(),
+            ]
+        ));
+
+        return $this->sendResponse($engine, 'Engine registered.');
+    }
+
+    /**
+     * Record a heartbeat. Executions the engine reports as running are
+     * attributed to it and no longer flagged as lost.
+     */
+    public function heartbeat(Request $request, string $engine): JsonResponse
+    {
+        $this->authorize('report', Engine::class);
+        $validated = $request->validate([
+            'status' => 'required|in:running,draining,stopped',
+            'worker_count' => 'required|integer|min:0',
+            'running_count' => 'required|integer|min:0',
+            'active_executions' => 'nullable|array',
+            'active_executions.*' => 'string',
+        ]);
+
+        $model = Engine::find($engine);
+        if (!$model) {
+            return $this->sendError('Engine is not registered.', [], 404);
+        }
+
+        $active = $validated['active_executions'] ?? [];
+        $model->update([
+            'status' => $validated['status'],
+            'worker_count' => $validated['worker_count'],
+            'running_count' => $validated['running_count'],
+            'active_executions' => $active,
+            'last_heartbeat_at' => now(),
+        ]);
+
+        if (!empty($active)) {
+            Execution::whereIn('id', $active)->update([
+                'engine_id' => $model->id,
+                'engine_lost_at' => null,
+            ]);
+        }
+
+        return $this->sendResponse(null, 'Heartbeat recorded.');
+    }
+}
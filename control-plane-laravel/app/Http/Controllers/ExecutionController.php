@@ -76,6 +76,7 @@ public function store(Request $request): JsonResponse
             'triggered_by' => $request->user()->id,
             'parameters' => $request->parameters,
             'notification_emails' => $request->notification_emails,
+            'priority' => 'high', // Ad-hoc runs must not wait behind scheduled batches
             'ftp_server_id' => $report->ftp_server_id // Link execution to FTP server for statistics
         ]);
 
@@ -95,6 +96,15 @@ public function update(Request $request, Execution $execution, \App\Services\Sch
         ]);
         $data = $request->all();
 
+        // A final status settles the job for engines that poll, even if the
+        // engine's release never arrives.
+        $settlesClaim = in_array($data['status'] ?? null, ['completed', 'failed', 'cancelled'], true);
+
+        // Only a "retrying" execution has a next retry.
+        if (isset($data['status']) && $data['status'] !== 'retrying') {
+            $data['next_retry_at'] = null;
+        }
+
         // Handle OTP from engine
         if ($request->has('otp')) {
             $data['otp_code'] = $request->otp;
@@ -103,8 +113,11 @@ public function update(Request $request, Execution $execution, \App\Services\Sch
             $data['otp_validated'] = false;
         }
 
-        \Illuminate\Support\Facades\DB::transaction(function() use ($execution, $data, $executor) {
+        \Illuminate\Support\Facades\DB::transaction(function() use ($execution, $data, $executor, $settlesClaim) {
             $execution->update($data);
+            if ($settlesClaim) {
+                $execution->settleClaim();
+            }
 
             // Notify if completed
             if ($execution->status === 'completed') {
@@ -148,29 +161,15 @@ public function update(Request $request, Execution $execution, \App\Services\Sch
                     }
                 }
             } else if ($execution->status === 'failed') {
-                // Auto-Retry logic
-                if ($execution->retry_count < ($execution->max_retries ?? 3)) {
-                    $delay = pow(2, $execution->retry_count) * 60; // 60s, 120s, 240s...
-                    
-                    \App\Jobs\ExecuteReportJob::dispatch($execution->id)->delay(now()->addSeconds($delay));
-                    
-                    $execution->update([
-                        'status' => 'pending', // Re-queueing
-                        'last_retry_at' => now(),
-                        'error_log' => ($execution->error_log ?? '') . "\n[System] Scheduled retry " . ($execution->retry_count + 1) . " in $delay seconds."
-                    ]);
-
-                    Log::info("Execution failed, scheduled retry", ['id' => $execution->id, 'delay' => $delay]);
-                    return; // Don't notify yet
-                }
-
-                // Notify failure after all retries
+                // The engine retries under the job's retry_policy and reports
+                // "failed" only once it gives up. Dispatching the execution
+                // again would hit the engine's done marker and be skipped.
                 $userId = $execution->triggered_by;
                 $details = [
                     'report_name' => $execution->report?->name ?? 'Untitled Report',
                     'execution_id' => $execution->id,
                     'type' => 'error',
-                    'message' => 'Report execution failed after ' . $execution->retry_count . ' attempts: ' . ($execution->error_log ?? 'Critical engine error')
+                    'message' => 'Report execution failed after ' . ($execution->attempt ?? 1) . ' attempts: ' . ($execution->error_log ?? 'Critical engine error')
                 ];
 
                 if ($userId) {
@@ -207,6 +206,192 @@ public function show(Execution $execution): JsonResponse
         return $this->sendResponse(new \App\Http\Resources\ExecutionResource($execution->load(['report', 'triggeredByUser'])), 'Execution retrieved successfully.');
     }
 
+    /**
+     * Ask the execution engines to cancel a queued or running execution.
+     *
+     * The cancel key is set before publishing so engines that pick the job up
+     * later still honour it; the engine reports the final "cancelled" status.
+     */
+    public function cancel(Execution $execution): JsonResponse
+    {
+        $this->authorize('cancel', $execution);
+
+        if (in_array($execution->status, ['completed', 'failed', 'cancelled'], true)) {
+            return $this->sendError('Execution has already finished.', [], 422);
+        }
+
+        if (config('services.engine.mode') === 'poll') {
+            return $this->cancelClaimable($execution);
+        }
+
+        $queueName = config('services.engine.queue');
+        \Illuminate\Support\Facades\Redis::setex("{$queueName}:cancel:{$execution->id}", 86400, 1);
+        \Illuminate\Support\Facades\Redis::publish("{$queueName}:control", json_encode([
+            'action' => 'cancel',
+            'execution_id' => $execution->id,
+        ]));
+
+        $this->auditService->log('cancel_execution', 'execution', $execution->id);
+
+        return $this->sendResponse(new \App\Http\Resources\ExecutionResource($execution), 'Cancellation requested.', 202);
+    }
+
+    /**
+     * Cancel an execution queued for polling engines. A job no engine holds
+     * is dropped right away; a claimed one is flagged, and the engine sees
+     * the flag on its next lease renewal and reports "cancelled" itself.
+     */
+    private function cancelClaimable(Execution $execution): JsonResponse
+    {
+        \Illuminate\Support\Facades\DB::transaction(function () use ($execution) {
+            $execution = Execution::lockForUpdate()->find($execution->id);
+            $held = $execution->lease_expires_at && $execution->lease_expires_at->isFuture();
+
+            if ($held) {
+                $execution->update(['cancel_requested_at' => now()]);
+                return;
+            }
+            $execution->update([
+                'status' => 'cancelled',
+                'finished_at' => now(),
+            ]);
+            $execution->settleClaim();
+        });
+
+        $this->auditService->log('cancel_execution', 'execution', $execution->id);
+
+        return $this->sendResponse(new \App\Http\Resources\ExecutionResource($execution->fresh()), 'Cancellation requested.', 202);
+    }
+
+    /**
+     * Hand queued executions to a polling engine.
+     *
+     * Jobs are claimed in priority order once they are due and no engine
+     * holds them, or the holder let its lease expire. The token returned
+     * with each job must accompany its renewals and release.
+     */
+    public function claim(Request $request): JsonResponse
+    {
+        $this->authorize('claim', Execution::class);
+        $request->validate([
+            'engine_id' => 'required|string|max:255',
+            'limit' => 'nullable|integer|min:1|max:50',
+            'lease_seconds' => 'nullable|integer|min:30|max:3600',
+        ]);
+        $lease = $request->integer('lease_seconds', 60);
+
+        $claims = \Illuminate\Support\Facades\DB::transaction(function () use ($request, $lease) {
+            // Cancelling a held job is left to its engine. If the lease ran
+            // out first, that engine is gone and nobody else will claim the
+            // job, so settle it here.
+            Execution::query()
+                ->whereNotNull('job_payload')
+                ->whereNotNull('cancel_requested_at')
+                ->where('lease_expires_at', '<', now())
+                ->update([
+                    'status' => 'cancelled',
+                    'finished_at' => now(),
+                    'job_payload' => null,
+                    'claimed_by' => null,
+                    'claim_token' => null,
+                    'lease_expires_at' => null,
+                ]);
+
+            $executions = Execution::query()
+                ->whereNotNull('job_payload')
+                ->whereNull('cancel_requested_at')
+                ->where(fn ($q) => $q->whereNull('available_at')->orWhere('available_at', '<=', now()))
+                ->where(fn ($q) => $q->whereNull('lease_expires_at')->orWhere('lease_expires_at', '<', now()))
+                ->orderByRaw("CASE priority WHEN 'high' THEN 0 WHEN 'low' THEN 2 ELSE 1 END")
+                ->orderBy('available_at')
+                ->limit($request->integer('limit', 1))
+                ->lockForUpdate()
+                ->get();
+
+            return $executions->map(function (Execution $execution) use ($request, $lease) {
+                $execution->forceFill([
+                    'claimed_by' => $request->engine_id,
+                    'claim_token' => \Illuminate\Support\Str::random(40),
+                    'lease_expires_at' => now()->addSeconds($lease),
+                ])->save();
+
+                return [
+                    'execution_id' => $execution->id,
+                    'token' => $execution->claim_token,
+                    'payload' => $execution->job_payload,
+                    'lease_expires_at' => $execution->lease_expires_at,
+                ];
+            })->values();
+        });
+
+        return $this->sendResponse($claims, 'Executions claimed.');
+    }
+
+    /**
+     * Extend the lease of a claimed execution. The response tells the
+     * engine whether the execution was cancelled in the meantime.
+     */
+    public function renewClaim(Request $request, Execution $execution): JsonResponse
+    {
+        $this->authorize('claim', Execution::class);
+        $request->validate([
+            'token' => 'required|string',
+            'lease_seconds' => 'nullable|integer|min:30|max:3600',
+        ]);
+
+        $updated = Execution::whereKey($execution->id)
+            ->where('claim_token', $request->token)
+            ->update(['lease_expires_at' => now()->addSeconds($request->integer('lease_seconds', 60))]);
+        if (!$updated) {
+            return $this->sendError('Claim is no longer held.', [], 409);
+        }
+
+        return $this->sendResponse([
+            'cancelled' => $execution->fresh()->cancel_requested_at !== null,
+        ], 'Claim renewed.');
+    }
+
+    /**
+     * Give a claimed execution back. With "done" the job is dropped;
+     * otherwise it can be claimed again from "available_at", or right away,
+     * with "payload" replacing the stored job when given.
+     */
+    public function releaseClaim(Request $request, Execution $execution): JsonResponse
+    {
+        $this->authorize('claim', Execution::class);
+        $request->validate([
+            'token' => 'required|string',
+            'done' => 'nullable|boolean',
+            'payload' => 'nullable|array',
+            'available_at' => 'nullable|date',
+        ]);
+
+        $changes = [
+            'claimed_by' => null,
+            'claim_token' => null,
+            'lease_expires_at' => null,
+        ];
+        if ($request->boolean('done')) {
+            $changes['job_payload'] = null;
+        } else {
+            if ($request->has('payload')) {
+                $changes['job_payload'] = json_encode($request->input('payload'));
+            }
+            $changes['available_at'] = $request->filled('available_at')
+                ? \Illuminate\Support\Carbon::parse($request->available_at)
+                : now();
+        }
+
+        $updated = Execution::whereKey($execution->id)
+            ->where('claim_token', $request->token)
+            ->update($changes);
+        if (!$updated) {
+            return $this->sendError('Claim is no longer held.', [], 409);
+        }
+
+        return $this->sendResponse(null, 'Claim released.');
+    }
+
     /**
      * Preview the content of the generated report file.
      */
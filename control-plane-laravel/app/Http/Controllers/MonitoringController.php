@@ -2,6 +2,7 @@
 
 namespace App\Http\Controllers;
 
+use App\Models\Engine;
 use App\Models\Execution;
 use Illuminate\Http\JsonResponse;
 use Illuminate\Support\Facades\DB;
@@ -15,6 +16,7 @@ public function index(): JsonResponse
             'queue' => [
                 'pending_jobs' => $this->getQueueSize(),
                 'active_executions' => Execution::where('status', 'processing')->count(),
+                'retrying_executions' => Execution::where('status', 'retrying')->count(),
                 'failed_last_24h' => Execution::where('status', 'failed')
                     ->where('updated_at', '>=', now()->subDay())
                     ->count(),
@@ -25,6 +27,7 @@ public function index(): JsonResponse
                     ->avg(DB::raw('TIMESTAMPDIFF(SECOND, started_at, finished_at)')), 2),
                 'success_rate' => $this->calculateSuccessRate(),
             ],
+            'engines' => $this->getEngines(),
             'system' => [
                 'disk_usage' => $this->getDiskUsage(),
                 'memory_usage' => $this->getMemoryUsage(),
@@ -44,6 +47,31 @@ private function getQueueSize(): int
         }
     }
 
+    private function getEngines(): array
+    {
+        $engines = Engine::orderBy('id')->get();
+
+        return [
+            'online' => $engines->whereIn('status', ['running', 'draining'])->count(),
+            'lost' => $engines->where('status', 'lost')->count(),
+            'workers' => $engines->whereIn('status', ['running', 'draining'])->sum('worker_count'),
+            'executions_on_lost_engines' => Execution::where('status', 'processing')
+                ->whereNotNull('engine_lost_at')
+                ->count(),
+            'fleet' => $engines->map(fn (Engine $engine) => [
+                'id' => $engine->id,
+                'version' => $engine->version,
+                'hostname' => $engine->hostname,
+                'status' => $engine->status,
+                'worker_count' => $engine->worker_count,
+                'running_count' => $engine->running_count,
+                'active_executions' => $engine->active_executions ?? [],
+                'last_heartbeat_at' => $engine->last_heartbeat_at,
+                'overdue' => $engine->isOverdue(),
+            ])->values(),
+        ];
+    }
+
     private function calculateSuccessRate(): float
     {
         $total = Execution::where('created_at', '>=', now()->subDay())->count();
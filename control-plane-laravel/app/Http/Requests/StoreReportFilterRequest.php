@@ -31,7 +31,7 @@ public function rules(): array
             'report_id' => 'required|exists:reports,id',
             'label' => 'required|string|max:255',
             'variable_name' => 'required|string|max:255',
-            'filter_type' => 'required|string|in:text,number,date,date_range,select',
+            'filter_type' => 'required|string|in:text,number,date,date_range,select,datetime,integer,decimal,boolean,string,list',
             'is_required' => 'boolean',
             'default_value' => 'nullable|string',
             'order_position' => 'nullable|integer',
@@ -29,7 +29,7 @@ public function rules(): array
             'report_id' => 'sometimes|exists:reports,id',
             'label' => 'sometimes|string|max:255',
             'variable_name' => 'sometimes|string|max:255',
-            'filter_type' => 'sometimes|string|in:text,number,date,date_range,select',
+            'filter_type' => 'sometimes|string|in:text,number,date,date_range,select,datetime,integer,decimal,boolean,string,list',
             'is_required' => 'boolean',
             'default_value' => 'nullable|string',
             'order_position' => 'integer',
@@ -14,6 +14,11 @@ public function toArray(Request $request): array
             'report_id' => $this->report_id,
             'report' => new ReportResource($this->whenLoaded('report')),
             'status' => $this->status,
+            'progress' => $this->progress,
+            'attempt' => $this->attempt,
+            'next_retry_at' => $this->next_retry_at,
+            'engine_id' => $this->engine_id,
+            'engine_lost_at' => $this->engine_lost_at,
             'started_at' => $this->started_at,
             'finished_at' => $this->finished_at,
             'output_path' => $this->output_path,
@@ -76,22 +76,51 @@ public function handle(): void
             $payload['sql_definition'] = $report->sql_definition;
         }
 
-        // Handle Parameterized Bindings
-        if (!empty($execution->parameters)) {
-            $payload['bindings'] = array_values($execution->parameters);
-        } else {
-            $payload['bindings'] = [];
+        // Handle Parameterized Bindings: in the order of the report's filters,
+        // which the engine uses to type them, then any values without one.
+        $parameters = $execution->parameters ?? [];
+        $bindings = [];
+        foreach ($report->filters()->orderBy('order_position')->pluck('variable_name') as $name) {
+            if (array_key_exists($name, $parameters)) {
+                $bindings[] = $parameters[$name];
+                unset($parameters[$name]);
+            } else {
+                $bindings[] = null;
+            }
         }
+        $payload['bindings'] = empty($execution->parameters)
+            ? []
+            : array_merge($bindings, array_values($parameters));
+        // Named values for :name / {{name}} parameters, resolved by the engine
+        // against the report's filters.
+        $payload['parameters'] = (object) ($execution->parameters ?? []);
 
         try {
-            // Push to Redis with priority support
-            $queueName = 'rbdb_execution_queue';
-            // In the future, we can push to specific priority queues:
-            // if ($payload['priority'] === 'high') $queueName .= '_high';
+            // Push to Redis with priority support:
+            // high and low get their own lanes, medium stays on the base list.
+            $queueName = config('services.engine.queue');
+            if (in_array($payload['priority'], ['high', 'low'], true)) {
+                $queueName .= ':' . $payload['priority'];
+            }
             
-            \Illuminate\Support\Facades\Redis::rpush($queueName, json_encode($payload));
+            if (config('services.engine.mode') === 'poll') {
+                // Engines claim it from the executions table instead of Redis.
+                $queueName = 'executions table';
+                $execution->forceFill([
+                    'job_payload' => $payload,
+                    'available_at' => now(),
+                    'claimed_by' => null,
+                    'claim_token' => null,
+                    'lease_expires_at' => null,
+                ])->save();
+            } elseif (config('services.engine.mode') === 'stream') {
+                $queueName .= ':stream';
+                \Illuminate\Support\Facades\Redis::xadd($queueName, '*', ['payload' => json_encode($payload)]);
+            } else {
+                \Illuminate\Support\Facades\Redis::rpush($queueName, json_encode($payload));
+            }
             
-            Log::info("ExecuteReportJob: Pushed to Redis queue [$queueName]", [
+            Log::info("ExecuteReportJob: Queued for the engine [$queueName]", [
                 'execution_id' => $execution->id,
                 'job_id' => $payload['job_id'],
                 'priority' => $payload['priority']
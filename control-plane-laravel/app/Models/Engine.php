@@ -0,0 +1,62 @@
+<?php
+
+namespace App\Models;
+
+use Illuminate\Database\Eloquent\Model;
+
+class Engine extends Model
+{
+    public $incrementing = false;
+
+    protected $keyType = 'string';
+
+    protected $fillable = [
+        'id',
+        'version',
+        'hostname',
+        'queue_mode',
+        'worker_count',
+        'running_count',
+        'db_types',
+        'output_formats',
+        'delivery_types',
+        'active_executions',
+        'status',
+        'heartbeat_interval_seconds',
+        'started_at',
+        'last_heartbeat_at',
+    ];
+
+    protected $casts = [
+        'db_types' => 'array',
+        'output_formats' => 'array',
+        'delivery_types' => 'array',
+        'active_executions' => 'array',
+        'started_at' => 'datetime',
+        'last_heartbeat_at' => 'datetime',
+    ];
+
+    /**
+     * Engines miss this many heartbeats before they are considered lost.
+     */
+    public const MISSED_HEARTBEATS = 3;
+
+    public function executions()
+    {
+        return $this->hasMany(Execution::class);
+    }
+
+    /**
+     * Whether the engine should still be sending heartbeats but hasn't for
+     * MISSED_HEARTBEATS intervals.
+     */
+    public function isOverdue(): bool
+    {
+        if (!in_array($this->status, ['running', 'draining'], true)) {
+            return false;
+        }
+        $deadline = now()->subSeconds($this->heartbeat_interval_seconds * self::MISSED_HEARTBEATS);
+
+        return $this->last_heartbeat_at === null || $this->last_heartbeat_at->lt($deadline);
+    }
+}
@@ -43,6 +43,17 @@ class Execution extends Model
         'max_retries',
         'priority',
         'last_retry_at',
+        'attempt',
+        'next_retry_at',
+        'progress',
+        'available_at',
+        'cancel_requested_at',
+        'engine_id',
+        'engine_lost_at',
+    ];
+
+    protected $hidden = [
+        'claim_token',
     ];
 
     protected $casts = [
@@ -60,8 +71,32 @@ class Execution extends Model
         'deleted_at' => 'datetime',
         'ftp_deleted_at' => 'datetime',
         'last_retry_at' => 'datetime',
+        'attempt' => 'integer',
+        'next_retry_at' => 'datetime',
+        'progress' => 'array',
+        'job_payload' => 'array',
+        'available_at' => 'datetime',
+        'lease_expires_at' => 'datetime',
+        'cancel_requested_at' => 'datetime',
+        'engine_lost_at' => 'datetime',
     ];
 
+    /**
+     * Drop the job and the claim of an execution that reached a final status,
+     * so polling engines neither claim nor renew it again. The claim columns
+     * are not fillable: only ExecuteReportJob, the claim actions and this
+     * method write them.
+     */
+    public function settleClaim(): void
+    {
+        $this->forceFill([
+            'job_payload' => null,
+            'claimed_by' => null,
+            'claim_token' => null,
+            'lease_expires_at' => null,
+        ])->save();
+    }
+
     public function triggeredByUser()
     {
         return $this->belongsTo(User::class, 'triggered_by');
@@ -0,0 +1,25 @@
+<?php
+
+namespace App\Policies;
+
+use App\Models\User;
+
+class EnginePolicy
+{
+    /**
+     * Determine whether the user can view the engine fleet.
+     */
+    public function viewAny(User $user): bool
+    {
+        return true;
+    }
+
+    /**
+     * Determine whether the user can register engines and report their
+     * heartbeats, as engines do with their API token.
+     */
+    public function report(User $user): bool
+    {
+        return $user->role->name === 'Admin';
+    }
+}
@@ -39,6 +39,23 @@ public function update(User $user, Execution $execution): bool
         return $user->role->name === 'Admin'; // Only admins should update execution status manually if needed
     }
 
+    /**
+     * Determine whether the user can cancel a queued or running execution.
+     */
+    public function cancel(User $user, Execution $execution): bool
+    {
+        return $user->role->name === 'Admin' || $execution->triggered_by === $user->id;
+    }
+
+    /**
+     * Determine whether the user can claim queued executions, as engines
+     * polling the control plane do.
+     */
+    public function claim(User $user): bool
+    {
+        return $user->role->name === 'Admin';
+    }
+
     /**
      * Determine whether the user can delete the model.
      */
@@ -21,6 +21,7 @@
         $schedule->command('app:process-schedules')->everyMinute();
         $schedule->command('app:cleanup-executions')->daily();
         $schedule->command('reports:ftp-cleanup')->hourly();
+        $schedule->command('engines:check-heartbeats')->everyMinute();
         $schedule->job(new \App\Jobs\CleanupExpiredReportsJob)->daily();
     })
     ->withExceptions(function (Exceptions $exceptions): void {
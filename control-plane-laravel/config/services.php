@@ -28,6 +28,13 @@
         'region' => env('AWS_DEFAULT_REGION', 'us-east-1'),
     ],
 
+    'engine' => [
+        // Base Redis key of the execution queue; must match the engine's QUEUE_NAME.
+        'queue' => env('ENGINE_QUEUE_NAME', 'rbdb_execution_queue'),
+        // "list", "stream" or "poll"; must match the engine's QUEUE_MODE.
+        'mode' => env('ENGINE_QUEUE_MODE', 'list'),
+    ],
+
     'slack' => [
         'notifications' => [
             'bot_user_oauth_token' => env('SLACK_BOT_USER_OAUTH_TOKEN'),
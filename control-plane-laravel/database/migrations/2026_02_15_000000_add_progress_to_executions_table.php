@@ -0,0 +1,29 @@
+<?php
+
+use Illuminate\Database\Migrations\Migration;
+use Illuminate\Database\Schema\Blueprint;
+use Illuminate\Support\Facades\Schema;
+
+return new class extends Migration
+{
+    /**
+     * Run the migrations.
+     */
+    public function up(): void
+    {
+        Schema::table('executions', function (Blueprint $table) {
+            // Latest snapshot reported by the engine: stage, rows, bytes, elapsed_seconds
+            $table->json('progress')->nullable()->after('status');
+        });
+    }
+
+    /**
+     * Reverse the migrations.
+     */
+    public function down(): void
+    {
+        Schema::table('executions', function (Blueprint $table) {
+            $table->dropColumn('progress');
+        });
+    }
+};
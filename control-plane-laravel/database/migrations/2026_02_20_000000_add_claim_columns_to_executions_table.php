@@ -0,0 +1,36 @@
+<?php
+
+use Illuminate\Database\Migrations\Migration;
+use Illuminate\Database\Schema\Blueprint;
+use Illuminate\Support\Facades\Schema;
+
+return new class extends Migration
+{
+    /**
+     * Run the migrations.
+     */
+    public function up(): void
+    {
+        Schema::table('executions', function (Blueprint $table) {
+            // Job handed to engines that poll instead of reading Redis (ENGINE_QUEUE_MODE=poll)
+            $table->json('job_payload')->nullable()->after('progress');
+            $table->timestamp('available_at')->nullable()->after('job_payload');
+            $table->string('claimed_by')->nullable()->after('available_at');
+            $table->string('claim_token', 64)->nullable()->after('claimed_by');
+            $table->timestamp('lease_expires_at')->nullable()->after('claim_token');
+            $table->timestamp('cancel_requested_at')->nullable()->after('lease_expires_at');
+            $table->index('available_at');
+        });
+    }
+
+    /**
+     * Reverse the migrations.
+     */
+    public function down(): void
+    {
+        Schema::table('executions', function (Blueprint $table) {
+            $table->dropIndex(['available_at']);
+            $table->dropColumn(['job_payload', 'available_at', 'claimed_by', 'claim_token', 'lease_expires_at', 'cancel_requested_at']);
+        });
+    }
+};
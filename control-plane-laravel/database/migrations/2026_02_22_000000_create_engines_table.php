@@ -0,0 +1,54 @@
+<?php
+
+use Illuminate\Database\Migrations\Migration;
+use Illuminate\Database\Schema\Blueprint;
+use Illuminate\Support\Facades\Schema;
+
+return new class extends Migration
+{
+    /**
+     * Run the migrations.
+     */
+    public function up(): void
+    {
+        Schema::create('engines', function (Blueprint $table) {
+            // ENGINE_ID of the Go engine
+            $table->string('id')->primary();
+            $table->string('version')->nullable();
+            $table->string('hostname')->nullable();
+            $table->string('queue_mode', 20)->nullable();
+            $table->unsignedInteger('worker_count')->default(0);
+            $table->unsignedInteger('running_count')->default(0);
+            $table->json('db_types')->nullable();
+            $table->json('output_formats')->nullable();
+            $table->json('delivery_types')->nullable();
+            $table->json('active_executions')->nullable();
+            $table->string('status', 20)->default('running'); // running, draining, stopped, lost
+            $table->unsignedInteger('heartbeat_interval_seconds')->default(15);
+            $table->timestamp('started_at')->nullable();
+            $table->timestamp('last_heartbeat_at')->nullable();
+            $table->timestamps();
+            $table->index(['status', 'last_heartbeat_at']);
+        });
+
+        Schema::table('executions', function (Blueprint $table) {
+            $table->string('engine_id')->nullable()->after('cancel_requested_at');
+            // Set when the owning engine stopped sending heartbeats mid-run
+            $table->timestamp('engine_lost_at')->nullable()->after('engine_id');
+            $table->index('engine_id');
+        });
+    }
+
+    /**
+     * Reverse the migrations.
+     */
+    public function down(): void
+    {
+        Schema::table('executions', function (Blueprint $table) {
+            $table->dropIndex(['engine_id']);
+            $table->dropColumn(['engine_id', 'engine_lost_at']);
+        });
+
+        Schema::dropIfExists('engines');
+    }
+};
@@ -0,0 +1,30 @@
+<?php
+
+use Illuminate\Database\Migrations\Migration;
+use Illuminate\Database\Schema\Blueprint;
+use Illuminate\Support\Facades\Schema;
+
+return new class extends Migration
+{
+    /**
+     * Run the migrations.
+     */
+    public function up(): void
+    {
+        Schema::table('executions', function (Blueprint $table) {
+            // Reported by the engine, which retries under the job's retry_policy
+            $table->unsignedInteger('attempt')->nullable()->after('retry_count');
+            $table->timestamp('next_retry_at')->nullable()->after('attempt');
+        });
+    }
+
+    /**
+     * Reverse the migrations.
+     */
+    public function down(): void
+    {
+        Schema::table('executions', function (Blueprint $table) {
+            $table->dropColumn(['attempt', 'next_retry_at']);
+        });
+    }
+};
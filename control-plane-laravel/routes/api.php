@@ -106,8 +106,17 @@
         // Executions
         Route::post('executions', [ExecutionController::class, 'store'])->middleware('throttle:5,1');
         Route::get('executions/{execution}/preview', [ExecutionController::class, 'previewContent']);
+        Route::post('executions/{execution}/cancel', [ExecutionController::class, 'cancel']);
+        Route::post('executions/claim', [ExecutionController::class, 'claim']);
+        Route::post('executions/{execution}/claim/renew', [ExecutionController::class, 'renewClaim']);
+        Route::post('executions/{execution}/claim/release', [ExecutionController::class, 'releaseClaim']);
         Route::apiResource('executions', ExecutionController::class)->only(['index', 'update', 'show']);
 
+        // Engines
+        Route::get('engines', [\App\Http\Controllers\EngineController::class, 'index']);
+        Route::post('engines/register', [\App\Http\Controllers\EngineController::class, 'register']);
+        Route::post('engines/{engine}/heartbeat', [\App\Http\Controllers\EngineController::class, 'heartbeat']);
+
         // User Management
         Route::get('users/notifications', [\App\Http\Controllers\UserController::class, 'notifications']);
         Route::post('users/notifications/{id}/read', [\App\Http\Controllers\UserController::class, 'markNotificationRead']);